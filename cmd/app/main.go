@@ -1,17 +1,34 @@
 package main
 
 import (
+	"golang_daerah/config"
 	"golang_daerah/internal/database"
 	"golang_daerah/internal/handler"
 	"golang_daerah/internal/service"
 	"golang_daerah/pkg/jwtutil"
+	"golang_daerah/pkg/logging"
 	"golang_daerah/pkg/middleware"
+	"golang_daerah/pkg/notify"
 	"log"
+	"log/slog"
 	"net/http"
 )
 
 func main() {
 
+	// Structured logging; the standard log package writes through the same handler
+	logger, err := logging.New(config.GetLogSettings())
+	if err != nil {
+		log.Fatal("Invalid log configuration: ", err)
+	}
+	slog.SetDefault(logger)
+
+	keySet, err := jwtutil.LoadKeySet(config.GetJWTSettings())
+	if err != nil {
+		log.Fatal("Failed to load JWT keys: ", err)
+	}
+	jwtutil.SetKeySet(keySet)
+
 	allDBs := database.InitAllDatabases()
 	defer database.CloseAllDatabases(allDBs)
 
@@ -38,6 +55,7 @@ func main() {
 	passengerBase := &database.BaseMultiDBRepository{Dbs: allDBs}
 	trafficBase := &database.BaseMultiDBRepository{Dbs: allDBs}
 	mysqlTrafficBase := &database.BaseMultiDBRepository{Dbs: allDBs}
+	idempotencyBase := &database.BaseMultiDBRepository{Dbs: allDBs}
 
 	// Initialize repositories
 	// trafficHandler := httpDelivery.NewPostgresTrafficTicketSQLXRepository()
@@ -46,9 +64,20 @@ func main() {
 	// lautHandler := httpDelivery.NewLautSQLXRepository()
 	// userRepo := httpDelivery.NewUserRepository() // NEW: User repository using sql.DB
 
-	// Auth service (manages its own DBs internally)
-	userRepo := service.NewUserRepository()
-	authService := service.NewAuthService(userRepo)
+	// Auth service; its tables live in the store selected by AUTH_STORE
+	userRepo, err := service.NewUserRepository(allDBs, config.GetAuthStore())
+	if err != nil {
+		log.Fatal("Failed to initialize user repository: ", err)
+	}
+	// Password reset tokens are delivered by the notifier selected with NOTIFIER
+	notifier, err := notify.New(config.GetNotifierSettings())
+	if err != nil {
+		log.Fatal("Failed to initialize notifier: ", err)
+	}
+	authService := service.NewAuthService(userRepo, notifier)
+	apiKeyService := service.NewAPIKeyService(userRepo)
+	// Machine clients authenticate with X-API-Key instead of a bearer token
+	jwtutil.SetAPIKeyAuthenticator(apiKeyService)
 	// Initialize services
 	lautService := service.NewLautService(lautBase)
 	passengerService := service.NewPassengerPlaneService(passengerBase)
@@ -62,47 +91,193 @@ func main() {
 	trafficHandler := handler.NewTrafficHandler(trafficService)
 	mysqlTrafficHandler := handler.NewTrafficMySQLHandler(mysqlTrafficService)
 	authHandler := handler.NewAuthHandler(authService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
 	// trafficHandler := httpDelivery.NewTrafficTicketSQLXHandler(trafficRepo)
 	// mysqlHandler := httpDelivery.NewMySQLTrafficTicketSQLXHandler(mysqlRepo)
 	// passengerHandler := httpDelivery.NewPassengerPlaneSQLXHandler(passengerRepo)
 	// lautHandler := httpDelivery.NewLautSQLXHandler(lautRepo)
 	// authHandler := httpDelivery.NewUserHandler(userService)
 
+	// Replays retried creates that carry an Idempotency-Key
+	idempotent := middleware.IdempotencyMiddleware(service.NewIdempotencyStore(idempotencyBase), config.GetIdempotencyTTL())
+
+	// Single-record routes check the permission matching the HTTP method
+	ticketsItemPermissions := jwtutil.RequireMethodPermission(map[string]string{
+		http.MethodGet:    jwtutil.PermTicketsRead,
+		http.MethodPut:    jwtutil.PermTicketsUpdate,
+		http.MethodDelete: jwtutil.PermTicketsDelete,
+	})
+	passengersItemPermissions := jwtutil.RequireMethodPermission(map[string]string{
+		http.MethodGet:    jwtutil.PermPassengersRead,
+		http.MethodPut:    jwtutil.PermPassengersUpdate,
+		http.MethodDelete: jwtutil.PermPassengersDelete,
+	})
+	portsItemPermissions := jwtutil.RequireMethodPermission(map[string]string{
+		http.MethodGet:    jwtutil.PermPortsRead,
+		http.MethodPut:    jwtutil.PermPortsUpdate,
+		http.MethodDelete: jwtutil.PermPortsDelete,
+	})
+
+	// Rate limit policies; every route wrapped with the same policy shares one limiter, whose
+	// state lives in memory or in a store shared by all replicas (RATE_LIMIT_STORE)
+	limitStore, err := middleware.NewLimiterStore(config.GetRateLimitStoreSettings())
+	if err != nil {
+		log.Fatal("Invalid rate limit store configuration: ", err)
+	}
+	limits, err := middleware.NewRateLimitPolicies(config.GetRateLimitPolicies(), limitStore)
+	if err != nil {
+		log.Fatal("Invalid rate limit configuration: ", err)
+	}
+	authLimit := limits.Middleware("auth")
+	apiLimit := limits.Middleware("api")
+	adminLimit := limits.Middleware("admin")
+	// CORS policy per route group, so browser dashboards on other origins can call the API
+	cors, err := middleware.NewCORSPolicies(config.GetCORSPolicies())
+	if err != nil {
+		log.Fatal("Invalid CORS configuration: ", err)
+	}
+
+	// Middleware chains, outermost first. CORS answers preflights before authentication and rate
+	// limiting; authentication runs before the api and admin policies so they can count per user
+	// or API key.
+	public := middleware.NewChain(cors.Middleware("auth"), authLimit)
+	refresh := middleware.NewChain(cors.Middleware("auth"), apiLimit)
+	protected := middleware.NewChain(cors.Middleware("api"), jwtutil.AuthMiddleware, apiLimit)
+	admin := middleware.NewChain(cors.Middleware("admin"), jwtutil.AuthMiddleware, adminLimit)
+
+	// Per route request rules: the method allowlist, then JSON bodies up to the size limit.
+	// Create routes take arrays of records and get the larger bulk limit.
+	bodyLimits := config.GetBodyLimits()
+	jsonBody := middleware.JSONBody(bodyLimits.Default)
+	bulkJSONBody := middleware.JSONBody(bodyLimits.Bulk)
+	get := middleware.AllowMethods(http.MethodGet)
+	post := middleware.AllowMethods(http.MethodPost)
+	del := middleware.AllowMethods(http.MethodDelete)
+	item := middleware.AllowMethods(http.MethodGet, http.MethodPut, http.MethodDelete)
+
 	// Setup router
 	router := http.NewServeMux()
-	// rateLimit := middleware.RateLimitMiddleware(100, 10)
-	// protected := func(handler http.HandlerFunc) http.HandlerFunc {
-	// 	return rateLimit(jwtutil.AuthMiddleware(handler))
-	// }
 
 	// Register routes
 	router.HandleFunc("/api/traffic_tickets/postgres",
-		(jwtutil.AuthMiddleware(trafficHandler.GetPaginated)))
+		protected.Append(get, jwtutil.RequirePermission(jwtutil.PermTicketsRead)).Then(trafficHandler.GetPaginated))
 	router.HandleFunc("/api/traffic_tickets/postgres_create",
-		jwtutil.AuthMiddleware(middleware.RateLimitMiddleware(100, 10)(trafficHandler.Create)))
+		protected.Append(post, bulkJSONBody, jwtutil.RequirePermission(jwtutil.PermTicketsCreate), idempotent).Then(trafficHandler.Create))
+	router.HandleFunc("/api/traffic_tickets/postgres/{id}",
+		protected.Append(item, jsonBody, ticketsItemPermissions).Then(trafficHandler.Item))
+	router.HandleFunc("/api/traffic_tickets/postgres/{id}/restore",
+		protected.Append(post, jwtutil.RequirePermission(jwtutil.PermTicketsDelete)).Then(trafficHandler.Restore))
+	router.HandleFunc("/api/traffic_tickets/postgres/{id}/purge",
+		protected.Append(del, jwtutil.RequirePermission(jwtutil.PermRecordsPurge)).Then(trafficHandler.Purge))
 
 	router.HandleFunc("/api/traffic_tickets/mysql",
-		middleware.RateLimitMiddleware(100, 10)(jwtutil.AuthMiddleware(mysqlTrafficHandler.GetPaginated)))
+		protected.Append(get, jwtutil.RequirePermission(jwtutil.PermTicketsRead)).Then(mysqlTrafficHandler.GetPaginated))
 	router.HandleFunc("/api/traffic_tickets/mysql_create",
-		middleware.RateLimitMiddleware(100, 10)(jwtutil.AuthMiddleware(mysqlTrafficHandler.Create)))
+		protected.Append(post, bulkJSONBody, jwtutil.RequirePermission(jwtutil.PermTicketsCreate), idempotent).Then(mysqlTrafficHandler.Create))
+	router.HandleFunc("/api/traffic_tickets/mysql/{id}",
+		protected.Append(item, jsonBody, ticketsItemPermissions).Then(mysqlTrafficHandler.Item))
+	router.HandleFunc("/api/traffic_tickets/mysql/{id}/restore",
+		protected.Append(post, jwtutil.RequirePermission(jwtutil.PermTicketsDelete)).Then(mysqlTrafficHandler.Restore))
+	router.HandleFunc("/api/traffic_tickets/mysql/{id}/purge",
+		protected.Append(del, jwtutil.RequirePermission(jwtutil.PermRecordsPurge)).Then(mysqlTrafficHandler.Purge))
 
 	router.HandleFunc("/api/passengers",
-		middleware.RateLimitMiddleware(100, 10)(jwtutil.AuthMiddleware(passengerHandler.GetPaginated)))
+		protected.Append(get, jwtutil.RequirePermission(jwtutil.PermPassengersRead)).Then(passengerHandler.GetPaginated))
 	router.HandleFunc("/api/passengers/create",
-		middleware.RateLimitMiddleware(100, 10)(jwtutil.AuthMiddleware(passengerHandler.Create)))
+		protected.Append(post, bulkJSONBody, jwtutil.RequirePermission(jwtutil.PermPassengersCreate), idempotent).Then(passengerHandler.Create))
+	router.HandleFunc("/api/passengers/{id}",
+		protected.Append(item, jsonBody, passengersItemPermissions).Then(passengerHandler.Item))
+	router.HandleFunc("/api/passengers/{id}/restore",
+		protected.Append(post, jwtutil.RequirePermission(jwtutil.PermPassengersDelete)).Then(passengerHandler.Restore))
+	router.HandleFunc("/api/passengers/{id}/purge",
+		protected.Append(del, jwtutil.RequirePermission(jwtutil.PermRecordsPurge)).Then(passengerHandler.Purge))
 
 	router.HandleFunc("/api/terminals",
-		middleware.RateLimitMiddleware(100, 10)(jwtutil.AuthMiddleware(lautHandler.GetPaginated)))
+		protected.Append(get, jwtutil.RequirePermission(jwtutil.PermPortsRead)).Then(lautHandler.GetPaginated))
 	router.HandleFunc("/api/terminals/create",
-		middleware.RateLimitMiddleware(100, 10)(jwtutil.AuthMiddleware(lautHandler.Create)))
+		protected.Append(post, bulkJSONBody, jwtutil.RequirePermission(jwtutil.PermPortsCreate), idempotent).Then(lautHandler.Create))
+	// showall attaches passenger names, ticket rows and usernames to each port, so it needs
+	// the read permission of every table it reads
 	router.HandleFunc("/api/terminals/showall",
-		middleware.RateLimitMiddleware(100, 10)(jwtutil.AuthMiddleware(lautHandler.LautGetCompleteDataHandler)))
+		protected.Append(get, jwtutil.RequirePermission(jwtutil.PermPortsRead), jwtutil.RequirePermission(jwtutil.PermPassengersRead),
+			jwtutil.RequirePermission(jwtutil.PermTicketsRead), jwtutil.RequirePermission(jwtutil.PermUsersManage)).Then(lautHandler.LautGetCompleteDataHandler))
+	router.HandleFunc("/api/terminals/{id}",
+		protected.Append(item, jsonBody, portsItemPermissions).Then(lautHandler.Item))
+	router.HandleFunc("/api/terminals/{id}/restore",
+		protected.Append(post, jwtutil.RequirePermission(jwtutil.PermPortsDelete)).Then(lautHandler.Restore))
+	router.HandleFunc("/api/terminals/{id}/purge",
+		protected.Append(del, jwtutil.RequirePermission(jwtutil.PermRecordsPurge)).Then(lautHandler.Purge))
+
+	router.HandleFunc("/.well-known/jwks.json", jwtutil.JWKSHandler)
 
 	router.HandleFunc("/api/register",
-		middleware.RateLimitMiddleware(100, 10)(authHandler.Register))
+		public.Append(post, jsonBody).Then(authHandler.Register))
 	router.HandleFunc("/api/login",
-		middleware.RateLimitMiddleware(100, 10)(authHandler.Login))
+		public.Append(post, jsonBody).Then(authHandler.Login))
+	router.HandleFunc("/api/login/2fa",
+		public.Append(post, jsonBody).Then(authHandler.LoginTwoFactor))
+	router.HandleFunc("/api/password/forgot",
+		public.Append(post, jsonBody).Then(authHandler.ForgotPassword))
+	router.HandleFunc("/api/password/reset",
+		public.Append(post, jsonBody).Then(authHandler.ConfirmPasswordReset))
+	router.HandleFunc("/api/token/refresh",
+		refresh.Append(post, jsonBody).Then(authHandler.Refresh))
+	router.HandleFunc("/api/logout",
+		protected.Append(post, jsonBody).Then(authHandler.Logout))
+	router.HandleFunc("/api/me",
+		protected.Append(get).Then(authHandler.Me))
+	router.HandleFunc("/api/me/password",
+		protected.Append(post, jsonBody).Then(authHandler.ChangePassword))
+	router.HandleFunc("/api/me/email",
+		protected.Append(post, jsonBody).Then(authHandler.ChangeEmail))
+	router.HandleFunc("/api/me/2fa/enroll",
+		protected.Append(post, jsonBody).Then(authHandler.EnrollTOTP))
+	router.HandleFunc("/api/me/2fa/confirm",
+		protected.Append(post, jsonBody).Then(authHandler.ConfirmTOTP))
+	router.HandleFunc("/api/me/2fa/disable",
+		protected.Append(post, jsonBody).Then(authHandler.DisableTOTP))
+
+	manageUsers := admin.Append(jwtutil.RequirePermission(jwtutil.PermUsersManage))
+	router.HandleFunc("/api/admin/users",
+		manageUsers.Append(get).Then(authHandler.ListUsers))
+	router.HandleFunc("/api/admin/users/{id}",
+		manageUsers.Append(middleware.AllowMethods(http.MethodGet, http.MethodDelete)).Then(authHandler.User))
+	router.HandleFunc("/api/admin/users/{id}/disable",
+		manageUsers.Append(post, jsonBody).Then(authHandler.DisableUser))
+	router.HandleFunc("/api/admin/users/{id}/enable",
+		manageUsers.Append(post, jsonBody).Then(authHandler.EnableUser))
+	router.HandleFunc("/api/admin/users/{id}/reset-password",
+		manageUsers.Append(post, jsonBody).Then(authHandler.ResetPassword))
+	router.HandleFunc("/api/admin/users/{id}/unlock",
+		manageUsers.Append(post, jsonBody).Then(authHandler.UnlockUser))
+
+	manageAPIKeys := admin.Append(jwtutil.RequirePermission(jwtutil.PermAPIKeysManage))
+	router.HandleFunc("/api/admin/api-keys",
+		manageAPIKeys.Append(middleware.AllowMethods(http.MethodGet, http.MethodPost), jsonBody).Then(apiKeyHandler.Keys))
+	router.HandleFunc("/api/admin/api-keys/{id}",
+		manageAPIKeys.Append(del).Then(apiKeyHandler.Revoke))
+
+	// Resolve the client IP once per request, honoring forwarding headers of trusted proxies only
+	clientIP, err := middleware.NewClientIPResolver(config.GetTrustedProxies(), config.GetTrustedProxyHeader())
+	if err != nil {
+		log.Fatal("Invalid trusted proxy settings: ", err)
+	}
+
+	// gzip/deflate for large responses such as the terminal and passenger lists
+	compressor, err := middleware.NewCompressor(config.GetCompressionSettings())
+	if err != nil {
+		log.Fatal("Invalid compression configuration: ", err)
+	}
+
+	// Panics are recovered outermost; every request gets an ID and an access log line, with the
+	// client IP resolved before that. Compression sits inside, so the log records the bytes sent.
+	app := middleware.NewChain(
+		middleware.RecoverMiddleware,
+		clientIP.Middleware,
+		middleware.RequestLogMiddleware,
+		compressor.Middleware,
+	).Then(router.ServeHTTP)
 
 	log.Println("Server running on :8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	log.Fatal(http.ListenAndServe(":8080", app))
 }
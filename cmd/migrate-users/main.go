@@ -0,0 +1,207 @@
+// Command migrate-users copies the auth store from one database to another, e.g. from the
+// PostgreSQL "golang" database to the MySQL "auth" database before switching AUTH_STORE.
+//
+//	go run ./cmd/migrate-users -from golang -to auth
+//
+// Users keep their ids, so roles, TOTP secrets, recovery codes and API keys stay attached.
+// Refresh tokens, login failure counters and password reset tokens are not copied: users
+// simply log in again.
+// The target schema must already exist (migrations/golang or migrations/auth) and its users
+// table must be empty. Everything is written in one transaction.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang_daerah/config"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// stores are the databases that can hold the auth tables, keyed like database.InitAllDatabases
+var stores = map[string]func() *sqlx.DB{
+	"golang": config.InitGolangDBX,
+	"auth":   config.InitAuthDBX,
+}
+
+type column struct {
+	name string
+	kind byte // 's' string, 'i' integer, 't' timestamp
+}
+
+type table struct {
+	name    string
+	columns []column
+	serial  bool // id comes from a sequence on PostgreSQL
+}
+
+// tables in foreign key order
+var tables = []table{
+	{name: "users", serial: true, columns: []column{
+		{"id", 'i'}, {"username", 's'}, {"email", 's'}, {"password", 's'}, {"disabled_at", 't'},
+	}},
+	{name: "user_roles", columns: []column{
+		{"user_id", 'i'}, {"role", 's'},
+	}},
+	{name: "role_permissions", columns: []column{
+		{"role", 's'}, {"permission", 's'},
+	}},
+	{name: "user_totp", columns: []column{
+		{"user_id", 'i'}, {"secret", 's'}, {"created_at", 't'}, {"confirmed_at", 't'}, {"last_step", 'i'},
+	}},
+	{name: "user_recovery_codes", serial: true, columns: []column{
+		{"id", 'i'}, {"user_id", 'i'}, {"code_hash", 's'}, {"used_at", 't'},
+	}},
+	{name: "api_keys", serial: true, columns: []column{
+		{"id", 'i'}, {"name", 's'}, {"owner_id", 'i'}, {"key_prefix", 's'}, {"key_hash", 's'},
+		{"scopes", 's'}, {"created_by", 's'}, {"created_at", 't'}, {"expires_at", 't'},
+		{"last_used_at", 't'}, {"revoked_at", 't'},
+	}},
+}
+
+func main() {
+	from := flag.String("from", "golang", "source store (golang or auth)")
+	to := flag.String("to", "auth", "target store (golang or auth)")
+	dryRun := flag.Bool("dry-run", false, "count the rows to copy without writing anything")
+	flag.Parse()
+
+	if *from == *to {
+		log.Fatal("-from and -to must be different stores")
+	}
+	openFrom, ok := stores[*from]
+	if !ok {
+		log.Fatalf("unknown source store %q", *from)
+	}
+	openTo, ok := stores[*to]
+	if !ok {
+		log.Fatalf("unknown target store %q", *to)
+	}
+
+	src := openFrom()
+	defer src.Close()
+	dst := openTo()
+	defer dst.Close()
+
+	if err := migrate(src, dst, *dryRun); err != nil {
+		log.Fatal("Migration failed: ", err)
+	}
+}
+
+func migrate(src, dst *sqlx.DB, dryRun bool) error {
+	var existing int
+	if err := dst.Get(&existing, `SELECT COUNT(*) FROM users`); err != nil {
+		return fmt.Errorf("check target users: %w", err)
+	}
+	if existing > 0 {
+		return fmt.Errorf("target already has %d users, refusing to merge", existing)
+	}
+
+	if dryRun {
+		for _, t := range tables {
+			var n int
+			if err := src.Get(&n, "SELECT COUNT(*) FROM "+t.name); err != nil {
+				return fmt.Errorf("count %s: %w", t.name, err)
+			}
+			log.Printf("%s: %d rows would be copied", t.name, n)
+		}
+		return nil
+	}
+
+	tx, err := dst.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The target's seeded role permissions are replaced by the source's
+	if _, err := tx.Exec(`DELETE FROM role_permissions`); err != nil {
+		return fmt.Errorf("clear role_permissions: %w", err)
+	}
+
+	for _, t := range tables {
+		n, err := copyTable(src, tx, t)
+		if err != nil {
+			return fmt.Errorf("copy %s: %w", t.name, err)
+		}
+		log.Printf("%s: copied %d rows", t.name, n)
+
+		// Explicit ids do not advance PostgreSQL sequences; MySQL moves AUTO_INCREMENT itself
+		if t.serial && dst.DriverName() == "postgres" {
+			query := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %s`, t.name, t.name)
+			if _, err := tx.Exec(query); err != nil {
+				return fmt.Errorf("reset %s sequence: %w", t.name, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func copyTable(src *sqlx.DB, tx *sqlx.Tx, t table) (int, error) {
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.name
+	}
+	list := strings.Join(names, ", ")
+
+	rows, err := src.Query(fmt.Sprintf("SELECT %s FROM %s", list, t.name))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	insert := tx.Rebind(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		t.name, list, strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")))
+
+	count := 0
+	for rows.Next() {
+		dest := make([]interface{}, len(t.columns))
+		for i, c := range t.columns {
+			switch c.kind {
+			case 'i':
+				dest[i] = new(sql.NullInt64)
+			case 't':
+				dest[i] = new(sql.NullTime)
+			default:
+				dest[i] = new(sql.NullString)
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return count, err
+		}
+
+		args := make([]interface{}, len(dest))
+		for i, d := range dest {
+			args[i] = value(d)
+		}
+		if _, err := tx.Exec(insert, args...); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// value unwraps a scanned column. Timestamps are passed on in UTC, which is how the auth code
+// writes them on both dialects.
+func value(d interface{}) interface{} {
+	switch v := d.(type) {
+	case *sql.NullInt64:
+		if v.Valid {
+			return v.Int64
+		}
+	case *sql.NullTime:
+		if v.Valid {
+			return v.Time.UTC()
+		}
+	case *sql.NullString:
+		if v.Valid {
+			return v.String
+		}
+	}
+	return nil
+}
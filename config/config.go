@@ -210,6 +210,328 @@ func GetQueryTimeout() time.Duration {
 	return time.Duration(timeoutSeconds) * time.Second
 }
 
+// GetIdempotencyTTL returns how long stored Idempotency-Key responses can be replayed
+func GetIdempotencyTTL() time.Duration {
+	hours := getenvInt("IDEMPOTENCY_TTL_HOURS", 24)
+	return time.Duration(hours) * time.Hour
+}
+
+// GetAccessTokenTTL returns the lifetime of access tokens issued on login and refresh
+func GetAccessTokenTTL() time.Duration {
+	minutes := getenvInt("ACCESS_TOKEN_TTL_MINUTES", 15)
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetRefreshTokenTTL returns the lifetime of a refresh token
+func GetRefreshTokenTTL() time.Duration {
+	hours := getenvInt("REFRESH_TOKEN_TTL_HOURS", 720)
+	return time.Duration(hours) * time.Hour
+}
+
+// PasswordPolicy describes what a new password must look like
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	DenylistFile  string // optional extra common-password list, one password per line
+}
+
+// GetPasswordPolicy reads the password policy enforced on register and password change
+func GetPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     getenvInt("PASSWORD_MIN_LENGTH", 10),
+		RequireUpper:  getenvBool("PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:  getenvBool("PASSWORD_REQUIRE_LOWER", true),
+		RequireDigit:  getenvBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol: getenvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		DenylistFile:  getenv("PASSWORD_DENYLIST_FILE", ""),
+	}
+}
+
+// LoginThrottleSettings controls delays and lockouts after failed logins
+type LoginThrottleSettings struct {
+	Window             time.Duration // failures older than this are forgotten
+	BaseDelay          time.Duration // wait after the first failure, doubled for every further one
+	MaxDelay           time.Duration
+	AccountMaxFailures int // failures per account before it is locked
+	IPMaxFailures      int // failures per client IP before it is locked
+	LockoutDuration    time.Duration
+}
+
+// GetLoginThrottleSettings reads the login throttling configuration
+func GetLoginThrottleSettings() LoginThrottleSettings {
+	return LoginThrottleSettings{
+		Window:             time.Duration(getenvInt("LOGIN_FAILURE_WINDOW_MINUTES", 15)) * time.Minute,
+		BaseDelay:          time.Duration(getenvInt("LOGIN_BASE_DELAY_SECONDS", 1)) * time.Second,
+		MaxDelay:           time.Duration(getenvInt("LOGIN_MAX_DELAY_SECONDS", 60)) * time.Second,
+		AccountMaxFailures: getenvInt("LOGIN_ACCOUNT_MAX_FAILURES", 5),
+		IPMaxFailures:      getenvInt("LOGIN_IP_MAX_FAILURES", 20),
+		LockoutDuration:    time.Duration(getenvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute,
+	}
+}
+
+// GetAuthStore returns the database key (see database.InitAllDatabases) that holds users,
+// roles, sessions and the other auth tables: "golang" (PostgreSQL) or "auth" (MySQL)
+func GetAuthStore() string {
+	return getenv("AUTH_STORE", "golang")
+}
+
+// GetTOTPIssuer returns the issuer name authenticator apps show next to the account
+func GetTOTPIssuer() string {
+	return getenv("TOTP_ISSUER", "golang_daerah")
+}
+
+// GetPasswordResetTTL returns how long a password reset token can be used
+func GetPasswordResetTTL() time.Duration {
+	minutes := getenvInt("PASSWORD_RESET_TTL_MINUTES", 30)
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetPasswordResetURL returns the link sent in reset messages; the token is appended to it.
+// Empty means the message only contains the token.
+func GetPasswordResetURL() string {
+	return getenv("PASSWORD_RESET_URL", "")
+}
+
+// SMTPSettings describes the mail server used by the smtp notifier
+type SMTPSettings struct {
+	Host     string
+	Port     string
+	Username string // empty disables authentication
+	Password string
+	From     string
+	StartTLS bool // require STARTTLS; sending fails when the server does not offer it
+}
+
+// NotifierSettings selects how messages to users (password reset links) are delivered
+type NotifierSettings struct {
+	Kind    string // "log" or "smtp"; required, there is no default
+	LogFile string // log notifier: append messages to this file instead of the application log
+	SMTP    SMTPSettings
+}
+
+// GetNotifierSettings reads the notifier configuration
+func GetNotifierSettings() NotifierSettings {
+	return NotifierSettings{
+		Kind:    getenv("NOTIFIER", ""),
+		LogFile: getenv("NOTIFY_LOG_FILE", ""),
+		SMTP: SMTPSettings{
+			Host:     getenv("SMTP_HOST", "localhost"),
+			Port:     getenv("SMTP_PORT", "25"),
+			Username: getenv("SMTP_USERNAME", ""),
+			Password: getenv("SMTP_PASSWORD", ""),
+			From:     getenv("SMTP_FROM", "no-reply@localhost"),
+			StartTLS: getenvBool("SMTP_STARTTLS", true),
+		},
+	}
+}
+
+// GetTrustedProxies returns the CIDRs (or single IPs) of reverse proxies whose forwarding
+// header is believed. TRUSTED_PROXIES is comma separated; empty trusts none.
+func GetTrustedProxies() []string {
+	return getenvList("TRUSTED_PROXIES", "")
+}
+
+// GetTrustedProxyHeader returns the forwarding header the trusted proxies write:
+// "x-forwarded-for" (default) or "forwarded". The other header is never read.
+func GetTrustedProxyHeader() string {
+	return getenv("TRUSTED_PROXY_HEADER", "x-forwarded-for")
+}
+
+// CORSPolicy describes which cross-origin browser requests a group of routes accepts
+type CORSPolicy struct {
+	Name             string
+	AllowedOrigins   []string // exact origins, "https://*.example.com" for any subdomain, or "*"; empty disables CORS
+	AllowedMethods   []string
+	AllowedHeaders   []string // request headers a preflight may ask for
+	ExposedHeaders   []string // response headers scripts may read
+	AllowCredentials bool     // allow cookies and Authorization; cannot be combined with origin "*"
+	MaxAge           time.Duration
+}
+
+// GetCORSPolicies returns the CORS policies of the route groups in main.go: "api" for protected
+// routes, "admin" for /api/admin and "auth" for login, register, refresh and password reset.
+// "api" reads CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS,
+// CORS_EXPOSED_HEADERS, CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE_SECONDS; the other groups read
+// the same names with a CORS_ADMIN_ or CORS_AUTH_ prefix and fall back to the "api" values.
+func GetCORSPolicies() []CORSPolicy {
+	return []CORSPolicy{
+		corsPolicy("api", "CORS"),
+		corsPolicy("admin", "CORS_ADMIN"),
+		corsPolicy("auth", "CORS_AUTH"),
+	}
+}
+
+func corsPolicy(name, prefix string) CORSPolicy {
+	setting := func(suffix, def string) string {
+		return getenv(prefix+suffix, getenv("CORS"+suffix, def))
+	}
+	credentials, err := strconv.ParseBool(setting("_ALLOW_CREDENTIALS", "false"))
+	if err != nil {
+		credentials = false
+	}
+	maxAge, err := strconv.Atoi(setting("_MAX_AGE_SECONDS", "600"))
+	if err != nil {
+		maxAge = 600
+	}
+
+	return CORSPolicy{
+		Name:           name,
+		AllowedOrigins: splitList(setting("_ALLOWED_ORIGINS", "")),
+		AllowedMethods: splitList(setting("_ALLOWED_METHODS", "GET,POST,PUT,DELETE")),
+		AllowedHeaders: splitList(setting("_ALLOWED_HEADERS",
+			"Authorization,Content-Type,X-API-Key,Idempotency-Key,If-Match,X-Request-ID,X-Device-Name")),
+		ExposedHeaders: splitList(setting("_EXPOSED_HEADERS",
+			"X-Request-ID,ETag,Idempotent-Replayed,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Retry-After")),
+		AllowCredentials: credentials,
+		MaxAge:           time.Duration(maxAge) * time.Second,
+	}
+}
+
+// LogSettings selects the level and output format of the application log
+type LogSettings struct {
+	Level  string // "debug", "info", "warn" or "error"
+	Format string // "json" or "text"
+}
+
+// GetLogSettings reads LOG_LEVEL and LOG_FORMAT
+func GetLogSettings() LogSettings {
+	return LogSettings{
+		Level:  getenv("LOG_LEVEL", "info"),
+		Format: getenv("LOG_FORMAT", "json"),
+	}
+}
+
+// CompressionSettings configures gzip/deflate compression of responses
+type CompressionSettings struct {
+	Level   int // 1 (fastest) to 9 (smallest); 0 disables compression
+	MinSize int // bodies smaller than this many bytes are sent as they are
+}
+
+// GetCompressionSettings reads COMPRESSION_LEVEL and COMPRESSION_MIN_BYTES
+func GetCompressionSettings() CompressionSettings {
+	return CompressionSettings{
+		Level:   getenvInt("COMPRESSION_LEVEL", 5),
+		MinSize: getenvInt("COMPRESSION_MIN_BYTES", 1024),
+	}
+}
+
+// BodyLimits are the largest request bodies accepted, in bytes
+type BodyLimits struct {
+	Default int64 // login, updates and other single object bodies
+	Bulk    int64 // create routes, which take arrays of records
+}
+
+// GetBodyLimits reads BODY_LIMIT_BYTES and BODY_LIMIT_BULK_BYTES
+func GetBodyLimits() BodyLimits {
+	return BodyLimits{
+		Default: int64(getenvInt("BODY_LIMIT_BYTES", 1<<20)),
+		Bulk:    int64(getenvInt("BODY_LIMIT_BULK_BYTES", 10<<20)),
+	}
+}
+
+// RateLimitPolicy is a named rate limit. Every route wrapped with the same policy shares one limiter.
+type RateLimitPolicy struct {
+	Name      string
+	Requests  int    // requests per minute
+	Burst     int    // maximum burst capacity
+	Key       string // "user" (username or API key, IP for anonymous requests) or "ip"
+	Algorithm string // "token_bucket", "sliding_window" or "gcra"
+}
+
+// GetRateLimitPolicies returns the rate limit policies used by the routes in main.go:
+// "api" for protected routes, "admin" for /api/admin and "auth" for login, register and password reset.
+// Each reads <PREFIX>_REQUESTS, <PREFIX>_BURST, <PREFIX>_KEY and <PREFIX>_ALGORITHM.
+func GetRateLimitPolicies() []RateLimitPolicy {
+	return []RateLimitPolicy{
+		rateLimitPolicy("api", "RATE_LIMIT", 100, 10, "user"),
+		rateLimitPolicy("admin", "RATE_LIMIT_ADMIN", 60, 10, "user"),
+		rateLimitPolicy("auth", "RATE_LIMIT_AUTH", 10, 5, "ip"),
+	}
+}
+
+func rateLimitPolicy(name, prefix string, requests, burst int, key string) RateLimitPolicy {
+	return RateLimitPolicy{
+		Name:      name,
+		Requests:  getenvInt(prefix+"_REQUESTS", requests),
+		Burst:     getenvInt(prefix+"_BURST", burst),
+		Key:       getenv(prefix+"_KEY", key),
+		Algorithm: getenv(prefix+"_ALGORITHM", "token_bucket"),
+	}
+}
+
+// RateLimitStoreSettings selects where rate limit state is kept
+type RateLimitStoreSettings struct {
+	Store         string // "memory" (per replica) or "redis" (shared by all replicas)
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+	RedisTimeout  time.Duration // dial and per command timeout
+	RetryInterval time.Duration // how long to limit locally after the shared store failed
+}
+
+// GetRateLimitStoreSettings reads the rate limit store configuration
+func GetRateLimitStoreSettings() RateLimitStoreSettings {
+	return RateLimitStoreSettings{
+		Store:         getenv("RATE_LIMIT_STORE", "memory"),
+		RedisAddr:     getenv("RATE_LIMIT_REDIS_ADDR", "localhost:6379"),
+		RedisPassword: getenv("RATE_LIMIT_REDIS_PASSWORD", ""),
+		RedisDB:       getenvInt("RATE_LIMIT_REDIS_DB", 0),
+		RedisTimeout:  time.Duration(getenvInt("RATE_LIMIT_REDIS_TIMEOUT_MS", 100)) * time.Millisecond,
+		RetryInterval: time.Duration(getenvInt("RATE_LIMIT_REDIS_RETRY_SECONDS", 10)) * time.Second,
+	}
+}
+
+// JWTKeySpec points at one signing or verification key file
+type JWTKeySpec struct {
+	ID        string // kid written into the token header
+	Algorithm string // HS256, RS256 or EdDSA
+	Path      string // PEM file (RS256/EdDSA) or raw secret file (HS256)
+}
+
+// JWTSettings describes the keys used to sign and verify access tokens
+type JWTSettings struct {
+	SigningKeyID string       // kid of the key used for new tokens
+	Keys         []JWTKeySpec // every key accepted for verification, including the signing key
+	Secret       string       // shorthand for a single HS256 key when Keys is empty
+}
+
+// GetJWTSettings reads JWT key configuration.
+// JWT_KEYS is a ";" separated list of kid:algorithm:path entries, e.g.
+// "2026-10:RS256:/keys/2026-10.pem;2026-04:RS256:/keys/2026-04.pub.pem".
+// Keys whose file only holds a public key can verify but not sign, which is how old keys
+// stay valid during rotation. JWT_SIGNING_KEY_ID selects the signing key (default: first entry).
+func GetJWTSettings() JWTSettings {
+	settings := JWTSettings{
+		SigningKeyID: getenv("JWT_SIGNING_KEY_ID", ""),
+		Secret:       getenv("JWT_SECRET", ""),
+	}
+
+	for _, entry := range strings.Split(getenv("JWT_KEYS", ""), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			log.Printf("Ignoring malformed JWT_KEYS entry %q, expected kid:algorithm:path", entry)
+			continue
+		}
+		settings.Keys = append(settings.Keys, JWTKeySpec{
+			ID:        strings.TrimSpace(parts[0]),
+			Algorithm: strings.TrimSpace(parts[1]),
+			Path:      strings.TrimSpace(parts[2]),
+		})
+	}
+
+	if settings.SigningKeyID == "" && len(settings.Keys) > 0 {
+		settings.SigningKeyID = settings.Keys[0].ID
+	}
+	return settings
+}
+
 // getenvInt retrieves integer environment variable with fallback
 func getenvInt(key string, defaultValue int) int {
 	value := getenv(key, "")
@@ -222,6 +544,34 @@ func getenvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getenvBool retrieves boolean environment variable with fallback
+func getenvBool(key string, defaultValue bool) bool {
+	value := getenv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	if boolValue, err := strconv.ParseBool(value); err == nil {
+		return boolValue
+	}
+	return defaultValue
+}
+
+// getenvList retrieves a comma separated environment variable with fallback
+func getenvList(key, def string) []string {
+	return splitList(getenv(key, def))
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // getenv retrieves string environment variable with fallback
 func getenv(key, def string) string {
 	v := os.Getenv(key)
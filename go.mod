@@ -8,9 +8,7 @@ require (
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/jmoiron/sqlx v1.4.0
 	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.45.0
 )
 
-require (
-	filippo.io/edwards25519 v1.1.0 // indirect
-	golang.org/x/crypto v0.45.0 // indirect
-)
+require filippo.io/edwards25519 v1.1.0 // indirect
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"golang_daerah/config"
+	"golang_daerah/pkg/logging"
 	"errors"
+	"time"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -37,14 +39,22 @@ func convertToPostgresPlaceholders(query string) string {
 
 // QueryDB executes a query on a specific database
 func (r *BaseMultiDBRepository) QueryDB(dbName, query string, args ...interface{}) ([]map[string]interface{}, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
+	return r.QueryDBContext(context.Background(), dbName, query, args...)
+}
+
+// QueryDBContext is QueryDB bound to ctx; the query is cancelled with the request and logged
+// with its logger
+func (r *BaseMultiDBRepository) QueryDBContext(ctx context.Context, dbName, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.GetQueryTimeout())
 	defer cancel()
 
 	db := r.getDB(dbName)
 	if db.DriverName() == "postgres" {
 		query = convertToPostgresPlaceholders(query)
 	}
+	start := time.Now()
 	rows, err := db.QueryxContext(ctx, query, args...)
+	logQuery(ctx, dbName, query, start, err)
 	if err != nil {
 		return nil, HandleQueryError(err)
 	}
@@ -70,7 +80,12 @@ func (r *BaseMultiDBRepository) QueryDB(dbName, query string, args ...interface{
 }
 
 func (r *BaseMultiDBRepository) InsertDB(dbName, query string, data map[string]interface{}) error {
-	ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
+	return r.InsertDBContext(context.Background(), dbName, query, data)
+}
+
+// InsertDBContext is InsertDB bound to ctx
+func (r *BaseMultiDBRepository) InsertDBContext(ctx context.Context, dbName, query string, data map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, config.GetQueryTimeout())
 	defer cancel()
 
 	db := r.getDB(dbName)
@@ -80,13 +95,110 @@ func (r *BaseMultiDBRepository) InsertDB(dbName, query string, data map[string]i
 		query = convertToPostgresPlaceholders(query)
 	}
 
+	start := time.Now()
 	_, err := db.NamedExecContext(ctx, query, data)
+	logQuery(ctx, dbName, query, start, err)
 	if err != nil {
 		return HandleQueryError(err)
 	}
 	return nil
 }
 
+// InsertDBReturningID executes a named INSERT and returns the id of the new row
+func (r *BaseMultiDBRepository) InsertDBReturningID(dbName, query string, data map[string]interface{}) (int64, error) {
+	return r.InsertDBReturningIDContext(context.Background(), dbName, query, data)
+}
+
+// InsertDBReturningIDContext is InsertDBReturningID bound to ctx
+func (r *BaseMultiDBRepository) InsertDBReturningIDContext(ctx context.Context, dbName, query string, data map[string]interface{}) (int64, error) {
+	var id int64
+	err := r.WithConnContext(ctx, dbName, func(ctx context.Context, ext sqlx.ExtContext) error {
+		var err error
+		id, err = InsertReturningID(ctx, ext, query, data)
+		return err
+	})
+	return id, err
+}
+
+// WithConn runs fn against the given database with the usual query timeout.
+// It is the non-transactional counterpart of WithTx for helpers that accept sqlx.ExtContext.
+func (r *BaseMultiDBRepository) WithConn(dbName string, fn func(ctx context.Context, ext sqlx.ExtContext) error) error {
+	return r.WithConnContext(context.Background(), dbName, fn)
+}
+
+// WithConnContext is WithConn bound to ctx
+func (r *BaseMultiDBRepository) WithConnContext(ctx context.Context, dbName string, fn func(ctx context.Context, ext sqlx.ExtContext) error) error {
+	ctx, cancel := context.WithTimeout(ctx, config.GetQueryTimeout())
+	defer cancel()
+
+	return fn(ctx, r.getDB(dbName))
+}
+
+// WithTx runs fn inside a single transaction on the given database.
+// The transaction is committed when fn returns nil and rolled back otherwise.
+func (r *BaseMultiDBRepository) WithTx(dbName string, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	return r.WithTxContext(context.Background(), dbName, fn)
+}
+
+// WithTxContext is WithTx bound to ctx
+func (r *BaseMultiDBRepository) WithTxContext(ctx context.Context, dbName string, fn func(ctx context.Context, tx *sqlx.Tx) error) error {
+	ctx, cancel := context.WithTimeout(ctx, config.GetQueryTimeout())
+	defer cancel()
+
+	db := r.getDB(dbName)
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return HandleQueryError(err)
+	}
+
+	if err := fn(ctx, tx); err != nil {
+		tx.Rollback()
+		logging.FromContext(ctx).Warn("transaction rolled back", "db", dbName, "error", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return HandleQueryError(err)
+	}
+	return nil
+}
+
+// InsertReturningID executes a named INSERT on a connection or transaction and returns the new id.
+// It hides the driver difference: postgres needs RETURNING, mysql reports it through LastInsertId.
+func InsertReturningID(ctx context.Context, ext sqlx.ExtContext, query string, data map[string]interface{}) (int64, error) {
+	if ext.DriverName() == "postgres" {
+		rows, err := sqlx.NamedQueryContext(ctx, ext, query+" RETURNING id", data)
+		if err != nil {
+			return 0, HandleQueryError(err)
+		}
+		defer rows.Close()
+
+		var id int64
+		if rows.Next() {
+			if err := rows.Scan(&id); err != nil {
+				return 0, err
+			}
+		}
+		return id, HandleQueryError(rows.Err())
+	}
+
+	result, err := sqlx.NamedExecContext(ctx, ext, query, data)
+	if err != nil {
+		return 0, HandleQueryError(err)
+	}
+	return result.LastInsertId()
+}
+
+// NamedExec executes a named UPDATE or DELETE on a connection or transaction and returns the
+// number of affected rows
+func NamedExec(ctx context.Context, ext sqlx.ExtContext, query string, data map[string]interface{}) (int64, error) {
+	result, err := sqlx.NamedExecContext(ctx, ext, query, data)
+	if err != nil {
+		return 0, HandleQueryError(err)
+	}
+	return result.RowsAffected()
+}
+
 //example use
 // Insert single passenger
 // passengerData := map[string]interface{}{
@@ -113,7 +225,12 @@ func (r *BaseMultiDBRepository) InsertDB(dbName, query string, data map[string]i
 // ==================== UPDATE HELPER ====================
 // updateDB - Helper for UPDATE queries with named parameters
 func (r *BaseMultiDBRepository) UpdateDB(dbName, query string, data map[string]interface{}) (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
+	return r.UpdateDBContext(context.Background(), dbName, query, data)
+}
+
+// UpdateDBContext is UpdateDB bound to ctx
+func (r *BaseMultiDBRepository) UpdateDBContext(ctx context.Context, dbName, query string, data map[string]interface{}) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.GetQueryTimeout())
 	defer cancel()
 
 	db := r.getDB(dbName)
@@ -123,7 +240,9 @@ func (r *BaseMultiDBRepository) UpdateDB(dbName, query string, data map[string]i
 		query = convertToPostgresPlaceholders(query)
 	}
 
+	start := time.Now()
 	result, err := db.NamedExecContext(ctx, query, data)
+	logQuery(ctx, dbName, query, start, err)
 	if err != nil {
 		return 0, HandleQueryError(err)
 	}
@@ -163,7 +282,12 @@ func (r *BaseMultiDBRepository) UpdateDB(dbName, query string, data map[string]i
 // ==================== DELETE HELPER ====================
 // deleteDB - Helper for DELETE queries with positional parameters
 func (r *BaseMultiDBRepository) DeleteDB(dbName, query string, args ...interface{}) (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
+	return r.DeleteDBContext(context.Background(), dbName, query, args...)
+}
+
+// DeleteDBContext is DeleteDB bound to ctx
+func (r *BaseMultiDBRepository) DeleteDBContext(ctx context.Context, dbName, query string, args ...interface{}) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.GetQueryTimeout())
 	defer cancel()
 
 	db := r.getDB(dbName)
@@ -173,7 +297,9 @@ func (r *BaseMultiDBRepository) DeleteDB(dbName, query string, args ...interface
 		query = convertToPostgresPlaceholders(query)
 	}
 
+	start := time.Now()
 	result, err := db.ExecContext(ctx, query, args...)
+	logQuery(ctx, dbName, query, start, err)
 	if err != nil {
 		return 0, HandleQueryError(err)
 	}
@@ -230,6 +356,18 @@ func (r *BaseMultiDBRepository) getDB(dbName string) *sqlx.DB {
 // 	return err
 // }
 
+// logQuery writes the statement to the request logger: failures as warnings, everything else
+// at debug level. Arguments are left out since they can hold personal data.
+func logQuery(ctx context.Context, dbName, query string, start time.Time, err error) {
+	logger := logging.FromContext(ctx)
+	duration := float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		logger.Warn("query failed", "db", dbName, "query", query, "duration_ms", duration, "error", err)
+		return
+	}
+	logger.Debug("query", "db", dbName, "query", query, "duration_ms", duration)
+}
+
 func HandleQueryError(err error) error {
 	if err == context.DeadlineExceeded {
 		return errors.New("database query timeout: request took too long")
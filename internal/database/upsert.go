@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// UpsertReturningID inserts data into table, or updates the existing row that has the same
+// natural key. columns lists every column to write and keys the columns of the unique index
+// that identifies a row. Columns in insertOnly, such as created_by, keep their stored value on
+// update. It returns the row id and whether a new row was inserted.
+//
+// A soft deleted row with the same natural key is restored by the update, since the unique
+// index leaves no room for a new row beside it.
+//
+// PostgreSQL uses ON CONFLICT ... DO UPDATE, MySQL uses ON DUPLICATE KEY UPDATE.
+// Both bump the version column on update so ETags of re-imported rows change.
+func UpsertReturningID(ctx context.Context, ext sqlx.ExtContext, table string, columns, keys, insertOnly []string, data map[string]interface{}) (int64, bool, error) {
+	// Natural key columns never change on update either
+	keep := make(map[string]bool, len(keys)+len(insertOnly))
+	for _, column := range append(append([]string{}, keys...), insertOnly...) {
+		keep[column] = true
+	}
+
+	placeholders := make([]string, len(columns))
+	for i, column := range columns {
+		placeholders[i] = ":" + column
+	}
+	insert := `INSERT INTO ` + table + ` (` + strings.Join(columns, ", ") + `)
+		VALUES (` + strings.Join(placeholders, ", ") + `)`
+
+	if ext.DriverName() == "postgres" {
+		var assignments []string
+		for _, column := range columns {
+			if !keep[column] {
+				assignments = append(assignments, column+" = EXCLUDED."+column)
+			}
+		}
+		assignments = append(assignments, "deleted_at = NULL", "deleted_by = NULL", "version = "+table+".version + 1")
+
+		query := insert + `
+		ON CONFLICT (` + strings.Join(keys, ", ") + `) DO UPDATE SET ` + strings.Join(assignments, ", ") + `
+		RETURNING id, (xmax = 0) AS inserted`
+
+		rows, err := sqlx.NamedQueryContext(ctx, ext, query, data)
+		if err != nil {
+			return 0, false, HandleQueryError(err)
+		}
+		defer rows.Close()
+
+		var id int64
+		var inserted bool
+		if rows.Next() {
+			if err := rows.Scan(&id, &inserted); err != nil {
+				return 0, false, err
+			}
+		}
+		return id, inserted, HandleQueryError(rows.Err())
+	}
+
+	var assignments []string
+	for _, column := range columns {
+		if !keep[column] {
+			assignments = append(assignments, column+" = VALUES("+column+")")
+		}
+	}
+	// LAST_INSERT_ID(id) makes LastInsertId report the existing row on update
+	assignments = append(assignments, "deleted_at = NULL", "deleted_by = NULL", "version = version + 1", "id = LAST_INSERT_ID(id)")
+
+	query := insert + `
+		ON DUPLICATE KEY UPDATE ` + strings.Join(assignments, ", ")
+
+	result, err := sqlx.NamedExecContext(ctx, ext, query, data)
+	if err != nil {
+		return 0, false, HandleQueryError(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, false, err
+	}
+	// MySQL reports 1 affected row for an insert and 2 for an update
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, false, err
+	}
+	return id, affected == 1, nil
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"golang_daerah/internal/service"
+	"golang_daerah/pkg/response"
+	"net/http"
+	"strconv"
+)
+
+// APIKeyHandler serves the admin API key routes; main.go guards them with api_keys:manage
+type APIKeyHandler struct {
+	Service *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{Service: apiKeyService}
+}
+
+// Keys serves GET (list) and POST (create) on /api/admin/api-keys
+func (h *APIKeyHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := h.Service.List(r.Context())
+		if err != nil {
+			response.WriteInternalServerError(w, "Failed to list API keys: "+err.Error())
+			return
+		}
+		response.WriteSuccessResponseOK(w, keys, "API keys retrieved successfully")
+	case http.MethodPost:
+		h.create(w, r)
+	}
+}
+
+// create returns the raw key; it is never shown again
+func (h *APIKeyHandler) create(w http.ResponseWriter, r *http.Request) {
+	var input service.NewAPIKey
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	key, err := h.Service.Create(r.Context(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPayload):
+			response.WriteBadRequest(w, err.Error())
+		case errors.Is(err, service.ErrUserNotFound):
+			response.WriteBadRequest(w, "owner_id does not match a user")
+		default:
+			response.WriteInternalServerError(w, "Failed to create API key: "+err.Error())
+		}
+		return
+	}
+
+	response.WriteSuccessResponseCreated(w, key, "API key created, store the key now as it will not be shown again")
+}
+
+// Revoke serves DELETE /api/admin/api-keys/{id}
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		response.WriteBadRequest(w, "Invalid id")
+		return
+	}
+
+	if err := h.Service.Revoke(r.Context(), id); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			response.WriteNotFound(w, err.Error())
+			return
+		}
+		response.WriteInternalServerError(w, "Failed to revoke API key: "+err.Error())
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, map[string]int64{"id": id}, "API key revoked")
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"golang_daerah/internal/service"
+	"golang_daerah/pkg/response"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseAtomic reads the ?atomic=true|false switch of the create endpoints.
+// Bulk creates are all-or-nothing unless the client asks for best-effort.
+func parseAtomic(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("atomic")
+	if value == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// parseUpsertMode reads ?mode=insert|upsert. Upsert matches rows on their natural key
+// and updates them instead of inserting duplicates.
+func parseUpsertMode(r *http.Request) (bool, error) {
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "insert":
+		return false, nil
+	case "upsert":
+		return true, nil
+	default:
+		return false, errors.New("invalid mode " + strconv.Quote(mode) + ", expected insert or upsert")
+	}
+}
+
+// writeBulkResult maps a bulk create outcome onto the HTTP status:
+// 201 when every item was saved, 207 when only some were, 422 when none were.
+// verb is "created" or "saved" and only shapes the message.
+func writeBulkResult(w http.ResponseWriter, result *service.BulkResult, noun, verb string) {
+	switch {
+	case result.Failed == 0:
+		response.WriteSuccessResponseCreated(w, result, strings.ToUpper(noun[:1])+noun[1:]+" "+verb+" successfully")
+	case result.Succeeded == 0:
+		response.WriteErrorResponseWithData(w, http.StatusUnprocessableEntity, result, "No "+noun+" were "+verb)
+	default:
+		response.WriteMultiStatus(w, result, "Some "+noun+" could not be "+verb)
+	}
+}
+
+// writeCreateError reports errors that prevented a bulk create from running at all
+func writeCreateError(w http.ResponseWriter, err error, noun string) {
+	if errors.Is(err, service.ErrInvalidPayload) {
+		response.WriteBadRequest(w, err.Error())
+		return
+	}
+	response.WriteInternalServerError(w, "Failed to insert "+noun+": "+err.Error())
+}
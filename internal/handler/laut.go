@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"golang_daerah/internal/service"
 	"golang_daerah/pkg/response"
 	"io"
@@ -26,8 +27,13 @@ func (h *LautHandler) LautGetCompleteDataHandler(w http.ResponseWriter, r *http.
 		perPage = 10
 	}
 
+	includeDeleted, ok := parseIncludeDeleted(w, r)
+	if !ok {
+		return
+	}
+
 	offset := (page - 1) * perPage
-	data, err := h.service.GetCompleteData(perPage, offset)
+	data, err := h.service.GetCompleteData(r.Context(), perPage, offset, includeDeleted)
 	if err != nil {
 		response.WriteInternalServerError(w, "Failed to get complete data: "+err.Error())
 		return
@@ -58,17 +64,26 @@ func (h *LautHandler) GetPaginated(w http.ResponseWriter, r *http.Request) {
         perPage = 10
     }
     
+    includeDeleted, ok := parseIncludeDeleted(w, r)
+    if !ok {
+        return
+    }
+
     offset := (page - 1) * perPage
 
     // Build filters
     filters := make(map[string]string)
     for key, values := range r.Form {
-        if key != "page" && key != "perPage" && len(values) > 0 {
+        if key != "page" && key != "perPage" && key != "include_deleted" && len(values) > 0 {
             filters[key] = values[0]
         }
     }
 
-    data, err := h.service.GetPaginatedWithFilters(perPage, offset, filters)
+    data, err := h.service.GetPaginatedWithFilters(r.Context(), perPage, offset, filters, includeDeleted)
+    if errors.Is(err, service.ErrInvalidPayload) {
+        response.WriteBadRequest(w, err.Error())
+        return
+    }
     if err != nil {
         response.WriteInternalServerError(w, "Failed to get terminals: "+err.Error())
         return
@@ -93,10 +108,41 @@ func (h *LautHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.Create(body); err != nil {
-		response.WriteInternalServerError(w, "Failed to insert terminals: "+err.Error())
+	atomic, err := parseAtomic(r)
+	if err != nil {
+		response.WriteBadRequest(w, "Invalid atomic parameter, expected true or false")
+		return
+	}
+
+	upsert, err := parseUpsertMode(r)
+	if err != nil {
+		response.WriteBadRequest(w, err.Error())
 		return
 	}
 
-	response.WriteSuccessResponseCreated(w, []interface{}{}, "Terminals created successfully")
+	create, verb := h.service.Create, "created"
+	if upsert {
+		create, verb = h.service.Upsert, "saved"
+	}
+
+	result, err := create(r.Context(), body, atomic)
+	if err != nil {
+		writeCreateError(w, err, "terminals")
+		return
+	}
+
+	writeBulkResult(w, result, "terminals", verb)
+}
+
+// Item serves GET, PUT and DELETE on a single record
+func (h *LautHandler) Item(w http.ResponseWriter, r *http.Request) {
+	handleRecord(w, r, h.service, "Terminal")
+}
+
+func (h *LautHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	handleRestore(w, r, h.service, "Terminal")
+}
+
+func (h *LautHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	handlePurge(w, r, h.service, "Terminal")
 }
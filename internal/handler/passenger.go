@@ -26,8 +26,13 @@ func (h *PassengerHandler) GetPaginated(w http.ResponseWriter, r *http.Request)
 		perPage = 10
 	}
 
+	includeDeleted, ok := parseIncludeDeleted(w, r)
+	if !ok {
+		return
+	}
+
 	offset := (page - 1) * perPage
-	data, err := h.service.GetPaginated(perPage, offset)
+	data, err := h.service.GetPaginated(r.Context(), perPage, offset, includeDeleted)
 	if err != nil {
 		response.WriteInternalServerError(w, "Failed to get passengers: "+err.Error())
 		return
@@ -49,10 +54,41 @@ func (h *PassengerHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.Create(body); err != nil {
-		response.WriteInternalServerError(w, "Failed to insert passengers: "+err.Error())
+	atomic, err := parseAtomic(r)
+	if err != nil {
+		response.WriteBadRequest(w, "Invalid atomic parameter, expected true or false")
+		return
+	}
+
+	upsert, err := parseUpsertMode(r)
+	if err != nil {
+		response.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	create, verb := h.service.Create, "created"
+	if upsert {
+		create, verb = h.service.Upsert, "saved"
+	}
+
+	result, err := create(r.Context(), body, atomic)
+	if err != nil {
+		writeCreateError(w, err, "passengers")
 		return
 	}
 
-	response.WriteSuccessResponseCreated(w, []interface{}{}, "Passengers created successfully")
+	writeBulkResult(w, result, "passengers", verb)
+}
+
+// Item serves GET, PUT and DELETE on a single record
+func (h *PassengerHandler) Item(w http.ResponseWriter, r *http.Request) {
+	handleRecord(w, r, h.service, "Passenger")
+}
+
+func (h *PassengerHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	handleRestore(w, r, h.service, "Passenger")
+}
+
+func (h *PassengerHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	handlePurge(w, r, h.service, "Passenger")
 }
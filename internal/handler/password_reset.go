@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"golang_daerah/pkg/response"
+	"net/http"
+)
+
+// ForgotPassword serves POST /api/password/forgot with {"login"} (username or email).
+// The answer is the same whether or not the account exists.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+	if body.Login == "" {
+		response.WriteBadRequest(w, "login (username or email) is required")
+		return
+	}
+
+	if err := h.Service.RequestPasswordReset(r.Context(), body.Login, deviceInfo(r).IPAddress); err != nil {
+		response.WriteInternalServerError(w, "Failed to request password reset: "+err.Error())
+		return
+	}
+
+	response.WriteSuccessResponse(w, http.StatusAccepted, []interface{}{},
+		"If the account exists and has an email address, a reset token has been sent")
+}
+
+// ConfirmPasswordReset serves POST /api/password/reset with {"token", "new_password"}
+func (h *AuthHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := h.Service.ConfirmPasswordReset(r.Context(), body.Token, body.NewPassword); err != nil {
+		writeUserError(w, err, "reset password")
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, []interface{}{}, "Password reset, please login with the new password")
+}
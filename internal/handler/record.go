@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang_daerah/internal/service"
+	"golang_daerah/pkg/jwtutil"
+	"golang_daerah/pkg/response"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// recordService is implemented by every service that exposes single-record routes
+// The context carries the principal that writes are attributed to and bounds the queries.
+type recordService interface {
+	Get(ctx context.Context, id int64, includeDeleted bool) (map[string]interface{}, error)
+	Update(ctx context.Context, id, version int64, jsonData []byte) (int64, error)
+	Delete(ctx context.Context, id, version int64) error
+	Restore(ctx context.Context, id int64) (bool, error)
+	Purge(ctx context.Context, id int64) (bool, error)
+}
+
+// hasPermission checks the permissions of the principal set by AuthMiddleware
+func hasPermission(r *http.Request, permission string) bool {
+	principal, ok := jwtutil.PrincipalFromRequest(r)
+	return ok && principal.HasPermission(permission)
+}
+
+// parseID reads the {id} path segment
+func parseID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// parseIncludeDeleted reads ?include_deleted=true, which needs the records:read_deleted permission.
+// It writes the error response itself and returns ok=false when the request must stop.
+func parseIncludeDeleted(w http.ResponseWriter, r *http.Request) (include bool, ok bool) {
+	value := r.URL.Query().Get("include_deleted")
+	if value == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		response.WriteBadRequest(w, "Invalid include_deleted parameter, expected true or false")
+		return false, false
+	}
+	if include && !hasPermission(r, jwtutil.PermRecordsReadDeleted) {
+		response.WriteForbidden(w, "Permission "+jwtutil.PermRecordsReadDeleted+" is required for include_deleted")
+		return false, false
+	}
+	return include, true
+}
+
+// formatETag renders a record version as a strong entity tag
+func formatETag(version interface{}) string {
+	return `"` + fmt.Sprint(version) + `"`
+}
+
+// parseIfMatch reads the version the client expects from If-Match.
+// It writes 428 or 412 itself and returns ok=false when the request must stop.
+// If-Match uses strong comparison (RFC 9110), so a weak W/"..." validator never matches.
+func parseIfMatch(w http.ResponseWriter, r *http.Request) (version int64, ok bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		response.WritePreconditionRequired(w, "If-Match header with the record ETag is required")
+		return 0, false
+	}
+	if strings.HasPrefix(value, "W/") {
+		response.WritePreconditionFailed(w, "If-Match needs the strong record ETag, weak ETags never match")
+		return 0, false
+	}
+
+	value = strings.Trim(value, `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		response.WritePreconditionFailed(w, "If-Match does not match the current record version")
+		return 0, false
+	}
+	return version, true
+}
+
+// writeRecordError maps service errors of the single-record routes onto HTTP statuses
+func writeRecordError(w http.ResponseWriter, err error, action, noun string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		response.WriteNotFound(w, noun+" not found")
+	case errors.Is(err, service.ErrVersionConflict):
+		response.WritePreconditionFailed(w, noun+" was modified by someone else, reload it and retry")
+	case errors.Is(err, service.ErrInvalidPayload):
+		response.WriteBadRequest(w, err.Error())
+	default:
+		response.WriteInternalServerError(w, "Failed to "+action+" "+noun+": "+err.Error())
+	}
+}
+
+// handleRecord serves GET, PUT and DELETE on .../{id}
+func handleRecord(w http.ResponseWriter, r *http.Request, svc recordService, noun string) {
+	switch r.Method {
+	case http.MethodGet:
+		handleGet(w, r, svc, noun)
+	case http.MethodPut:
+		handleUpdate(w, r, svc, noun)
+	case http.MethodDelete:
+		handleSoftDelete(w, r, svc, noun)
+	}
+}
+
+// handleGet returns one record and its version as ETag
+func handleGet(w http.ResponseWriter, r *http.Request, svc recordService, noun string) {
+	id, ok := parseID(r)
+	if !ok {
+		response.WriteBadRequest(w, "Invalid id")
+		return
+	}
+	includeDeleted, ok := parseIncludeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	record, err := svc.Get(r.Context(), id, includeDeleted)
+	if err != nil {
+		writeRecordError(w, err, "get", noun)
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(record["version"]))
+	response.WriteSuccessResponseOK(w, record, noun+" retrieved successfully")
+}
+
+// handleUpdate applies a partial update guarded by If-Match
+func handleUpdate(w http.ResponseWriter, r *http.Request, svc recordService, noun string) {
+	id, ok := parseID(r)
+	if !ok {
+		response.WriteBadRequest(w, "Invalid id")
+		return
+	}
+	version, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.WriteBadRequest(w, "Invalid request body")
+		return
+	}
+
+	newVersion, err := svc.Update(r.Context(), id, version, body)
+	if err != nil {
+		writeRecordError(w, err, "update", noun)
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(newVersion))
+	response.WriteSuccessResponseOK(w, map[string]int64{"id": id, "version": newVersion}, noun+" updated successfully")
+}
+
+// handleSoftDelete soft deletes a record guarded by If-Match
+func handleSoftDelete(w http.ResponseWriter, r *http.Request, svc recordService, noun string) {
+	id, ok := parseID(r)
+	if !ok {
+		response.WriteBadRequest(w, "Invalid id")
+		return
+	}
+	version, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	if err := svc.Delete(r.Context(), id, version); err != nil {
+		writeRecordError(w, err, "delete", noun)
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, map[string]int64{"id": id}, noun+" deleted successfully")
+}
+
+// handleRestore serves POST .../{id}/restore
+func handleRestore(w http.ResponseWriter, r *http.Request, svc recordService, noun string) {
+	id, ok := parseID(r)
+	if !ok {
+		response.WriteBadRequest(w, "Invalid id")
+		return
+	}
+
+	restored, err := svc.Restore(r.Context(), id)
+	if err != nil {
+		response.WriteInternalServerError(w, "Failed to restore "+noun+": "+err.Error())
+		return
+	}
+	if !restored {
+		response.WriteNotFound(w, noun+" not found or not deleted")
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, map[string]int64{"id": id}, noun+" restored successfully")
+}
+
+// handlePurge serves DELETE .../{id}/purge; main.go guards it with records:purge
+func handlePurge(w http.ResponseWriter, r *http.Request, svc recordService, noun string) {
+	id, ok := parseID(r)
+	if !ok {
+		response.WriteBadRequest(w, "Invalid id")
+		return
+	}
+
+	purged, err := svc.Purge(r.Context(), id)
+	if err != nil {
+		response.WriteInternalServerError(w, "Failed to purge "+noun+": "+err.Error())
+		return
+	}
+	if !purged {
+		response.WriteNotFound(w, noun+" not found or not deleted")
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, map[string]int64{"id": id}, noun+" purged permanently")
+}
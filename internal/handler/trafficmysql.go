@@ -26,8 +26,13 @@ func (h *MySQLTrafficTicketHandler) GetPaginated(w http.ResponseWriter, r *http.
 		perPage = 10
 	}
 
+	includeDeleted, ok := parseIncludeDeleted(w, r)
+	if !ok {
+		return
+	}
+
 	offset := (page - 1) * perPage
-	data, err := h.service.GetPaginated(perPage, offset)
+	data, err := h.service.GetPaginated(r.Context(), perPage, offset, includeDeleted)
 	if err != nil {
 		response.WriteInternalServerError(w, "Failed to get tickets: "+err.Error())
 		return
@@ -49,10 +54,41 @@ func (h *MySQLTrafficTicketHandler) Create(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := h.service.Create(body); err != nil {
-		response.WriteInternalServerError(w, "Failed to insert tickets: "+err.Error())
+	atomic, err := parseAtomic(r)
+	if err != nil {
+		response.WriteBadRequest(w, "Invalid atomic parameter, expected true or false")
+		return
+	}
+
+	upsert, err := parseUpsertMode(r)
+	if err != nil {
+		response.WriteBadRequest(w, err.Error())
+		return
+	}
+
+	create, verb := h.service.Create, "created"
+	if upsert {
+		create, verb = h.service.Upsert, "saved"
+	}
+
+	result, err := create(r.Context(), body, atomic)
+	if err != nil {
+		writeCreateError(w, err, "tickets")
 		return
 	}
 
-	response.WriteSuccessResponseCreated(w, []interface{}{}, "Tickets created successfully")
+	writeBulkResult(w, result, "tickets", verb)
+}
+
+// Item serves GET, PUT and DELETE on a single record
+func (h *MySQLTrafficTicketHandler) Item(w http.ResponseWriter, r *http.Request) {
+	handleRecord(w, r, h.service, "Ticket")
+}
+
+func (h *MySQLTrafficTicketHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	handleRestore(w, r, h.service, "Ticket")
+}
+
+func (h *MySQLTrafficTicketHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	handlePurge(w, r, h.service, "Ticket")
 }
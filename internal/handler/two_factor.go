@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"golang_daerah/pkg/response"
+	"net/http"
+)
+
+// LoginTwoFactor serves POST /api/login/2fa, the second login step for users with TOTP enabled.
+// The body carries the challenge_token from /api/login and either a code or a recovery_code.
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+		DeviceName     string `json:"device_name,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	device := deviceInfo(r)
+	if device.Name == "" {
+		device.Name = body.DeviceName
+	}
+	tokens, err := h.Service.VerifyTwoFactor(r.Context(), body.ChallengeToken, body.Code, body.RecoveryCode, device)
+	if err != nil {
+		writeLoginError(w, err)
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, tokens, "Login successful")
+}
+
+// EnrollTOTP serves POST /api/me/2fa/enroll and returns a new secret with its otpauth URI
+func (h *AuthHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.Service.EnrollTOTP(r.Context(), principal.UserID)
+	if err != nil {
+		writeUserError(w, err, "enroll two-factor authentication")
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, enrollment, "Add the secret to your authenticator app and confirm with a code")
+}
+
+// ConfirmTOTP serves POST /api/me/2fa/confirm with {"code": "123456"} and returns the recovery codes
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	codes, err := h.Service.ConfirmTOTP(r.Context(), principal.UserID, body.Code)
+	if err != nil {
+		writeUserError(w, err, "confirm two-factor authentication")
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, map[string][]string{"recovery_codes": codes},
+		"Two-factor authentication enabled, store the recovery codes now as they will not be shown again")
+}
+
+// DisableTOTP serves POST /api/me/2fa/disable with the password and a code or recovery code
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := h.Service.DisableTOTP(r.Context(), principal.UserID, body.Password, body.Code, body.RecoveryCode); err != nil {
+		writeUserError(w, err, "disable two-factor authentication")
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, []interface{}{}, "Two-factor authentication disabled")
+}
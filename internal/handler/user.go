@@ -2,8 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"golang_daerah/internal/service"
 	"golang_daerah/pkg/jwtutil"
+	"golang_daerah/pkg/middleware"
 	"golang_daerah/pkg/response"
 	"net/http"
 )
@@ -17,18 +19,13 @@ func NewAuthHandler(authService *service.AuthService) *AuthHandler {
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		response.WriteMethodNotAllowed(w)
-		return
-	}
-
 	var creds service.Credentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
 		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
 		return
 	}
 
-	if err := h.Service.Register(creds); err != nil {
+	if err := h.Service.Register(r.Context(), creds); err != nil {
 		response.WriteBadRequest(w, err.Error())
 		return
 	}
@@ -37,38 +34,172 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		response.WriteMethodNotAllowed(w)
-		return
-	}
-
 	var creds service.Credentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
 		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
 		return
 	}
 
-	token, err := h.Service.Login(creds)
+	result, err := h.Service.Login(r.Context(), creds, deviceInfo(r))
 	if err != nil {
-		response.WriteUnauthorized(w, err.Error())
+		writeLoginError(w, err)
+		return
+	}
+
+	if result.TwoFactorRequired {
+		response.WriteSuccessResponseOK(w, result, "Two-factor code required, complete the login at /api/login/2fa")
 		return
 	}
+	response.WriteSuccessResponseOK(w, result, "Login successful")
+}
 
-	response.WriteSuccessResponseOK(w, map[string]string{"token": token}, "Login successful")
+// writeLoginError maps errors of both login steps onto HTTP statuses
+func writeLoginError(w http.ResponseWriter, err error) {
+	var throttled *service.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		response.WriteTooManyRequests(w, throttled.RetryAfter, throttled.Error())
+	case errors.Is(err, service.ErrInvalidCredentials),
+		errors.Is(err, service.ErrInvalidChallenge),
+		errors.Is(err, service.ErrInvalidTwoFactorCode):
+		response.WriteUnauthorized(w, err.Error())
+	case errors.Is(err, service.ErrAccountDisabled):
+		response.WriteForbidden(w, err.Error())
+	default:
+		response.WriteInternalServerError(w, "Failed to login: "+err.Error())
+	}
 }
 
-func (h *AuthHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		response.WriteUnauthorized(w, "Authorization header required")
+// Refresh exchanges a refresh token for a new access/refresh token pair
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
 		return
 	}
 
-	username, err := jwtutil.VerifyToken(authHeader)
+	tokens, err := h.Service.Refresh(r.Context(), body.RefreshToken, deviceInfo(r))
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			response.WriteUnauthorized(w, err.Error())
+			return
+		}
+		response.WriteInternalServerError(w, "Failed to refresh token: "+err.Error())
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, tokens, "Token refreshed")
+}
+
+// Logout revokes the session of the presented access token
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	principal, ok := jwtutil.PrincipalFromRequest(r)
+	if !ok {
 		response.WriteUnauthorized(w, "Invalid or expired token")
 		return
 	}
 
-	response.WriteSuccessResponseOK(w, map[string]string{"username": username}, "Welcome, "+username+"!")
+	if err := h.Service.Logout(r.Context(), principal.SessionID); err != nil {
+		response.WriteBadRequest(w, "Failed to logout: "+err.Error())
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, []interface{}{}, "Logged out")
+}
+
+// deviceInfo collects the session metadata stored with refresh tokens
+func deviceInfo(r *http.Request) service.DeviceInfo {
+	return service.DeviceInfo{
+		Name:      r.Header.Get("X-Device-Name"),
+		UserAgent: r.UserAgent(),
+		IPAddress: middleware.ClientIPFromRequest(r),
+	}
+}
+
+// Me serves GET /api/me: the profile of the principal that AuthMiddleware put into the request context
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	principal, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	profile, err := h.Service.Profile(r.Context(), principal.UserID)
+	if err != nil {
+		writeUserError(w, err, "load profile")
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, profile, "Welcome, "+principal.Username+"!")
+}
+
+// ChangePassword serves POST /api/me/password. Other sessions of the user are logged out.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	principal, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	err := h.Service.ChangePassword(r.Context(), principal.UserID, principal.SessionID, body.CurrentPassword, body.NewPassword)
+	if err != nil {
+		writeUserError(w, err, "change password")
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, []interface{}{}, "Password changed")
+}
+
+// ChangeEmail serves POST /api/me/email with {"current_password", "email"}; an empty email removes
+// the address, which also disables password resets for the account
+func (h *AuthHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	principal, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		Email           string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := h.Service.SetEmail(r.Context(), principal.UserID, body.CurrentPassword, body.Email); err != nil {
+		writeUserError(w, err, "change email")
+		return
+	}
+
+	response.WriteSuccessResponseOK(w, []interface{}{}, "Email changed")
+}
+
+// currentUser returns the principal of a request made by a logged in user, rejecting API keys
+// so a machine client cannot change the password or 2FA settings of its owner
+func currentUser(w http.ResponseWriter, r *http.Request) (*jwtutil.Principal, bool) {
+	principal, ok := jwtutil.PrincipalFromRequest(r)
+	if !ok {
+		response.WriteUnauthorized(w, "Authorization header required")
+		return nil, false
+	}
+	if principal.APIKeyID != 0 {
+		response.WriteForbidden(w, "This endpoint is not available to API keys")
+		return nil, false
+	}
+	if principal.UserID == 0 {
+		// Tokens issued before user ids were embedded
+		response.WriteUnauthorized(w, "Token has no user id, please login again")
+		return nil, false
+	}
+	return principal, true
 }
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"golang_daerah/internal/service"
+	"golang_daerah/pkg/response"
+	"net/http"
+	"strconv"
+)
+
+// Admin user management. main.go guards every route in this file with the users:manage permission.
+
+// ListUsers serves GET /api/admin/users?page=&perPage=
+func (h *AuthHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("perPage"))
+	if page <= 0 {
+		page = 1
+	}
+	if perPage <= 0 {
+		perPage = 10
+	}
+
+	users, err := h.Service.ListUsers(r.Context(), perPage, (page-1)*perPage)
+	if err != nil {
+		response.WriteInternalServerError(w, "Failed to list users: "+err.Error())
+		return
+	}
+
+	response.WritePaginatedResponse(w, users, page, perPage, "Users retrieved successfully")
+}
+
+// User serves GET and DELETE on /api/admin/users/{id}
+func (h *AuthHandler) User(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		profile, err := h.Service.Profile(r.Context(), id)
+		if err != nil {
+			writeUserError(w, err, "get user")
+			return
+		}
+		response.WriteSuccessResponseOK(w, profile, "User retrieved successfully")
+	case http.MethodDelete:
+		principal, ok := currentUser(w, r)
+		if !ok {
+			return
+		}
+		if err := h.Service.DeleteUser(r.Context(), principal.UserID, id); err != nil {
+			writeUserError(w, err, "delete user")
+			return
+		}
+		response.WriteSuccessResponseOK(w, map[string]int{"id": id}, "User deleted successfully")
+	}
+}
+
+// DisableUser serves POST /api/admin/users/{id}/disable and logs out every session of the user
+func (h *AuthHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	principal, ok := currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.DisableUser(r.Context(), principal.UserID, id); err != nil {
+		writeUserError(w, err, "disable user")
+		return
+	}
+	response.WriteSuccessResponseOK(w, map[string]int{"id": id}, "User disabled")
+}
+
+// EnableUser serves POST /api/admin/users/{id}/enable
+func (h *AuthHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.EnableUser(r.Context(), id); err != nil {
+		writeUserError(w, err, "enable user")
+		return
+	}
+	response.WriteSuccessResponseOK(w, map[string]int{"id": id}, "User enabled")
+}
+
+// ResetPassword serves POST /api/admin/users/{id}/reset-password with {"new_password": "..."}
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.WriteBadRequest(w, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := h.Service.ResetPassword(r.Context(), id, body.NewPassword); err != nil {
+		writeUserError(w, err, "reset password")
+		return
+	}
+	response.WriteSuccessResponseOK(w, map[string]int{"id": id}, "Password reset, all sessions of the user were logged out")
+}
+
+// UnlockUser serves POST /api/admin/users/{id}/unlock and lifts a login lockout
+func (h *AuthHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.UnlockUser(r.Context(), id); err != nil {
+		writeUserError(w, err, "unlock user")
+		return
+	}
+	response.WriteSuccessResponseOK(w, map[string]int{"id": id}, "User unlocked")
+}
+
+// parseUserID reads the {id} path segment of the user routes
+func parseUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		response.WriteBadRequest(w, "Invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+// writeUserError maps service errors of the user routes onto HTTP statuses
+func writeUserError(w http.ResponseWriter, err error, action string) {
+	var policy *service.PasswordPolicyError
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		response.WriteNotFound(w, err.Error())
+	case errors.As(err, &policy):
+		response.WriteBadRequest(w, err.Error())
+	case errors.Is(err, service.ErrWrongPassword), errors.Is(err, service.ErrSelfManagement):
+		response.WriteForbidden(w, err.Error())
+	case errors.Is(err, service.ErrInvalidTwoFactorCode), errors.Is(err, service.ErrTwoFactorNotEnrolled):
+		response.WriteBadRequest(w, err.Error())
+	case errors.Is(err, service.ErrInvalidEmail), errors.Is(err, service.ErrInvalidResetToken):
+		response.WriteBadRequest(w, err.Error())
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled), errors.Is(err, service.ErrEmailTaken):
+		response.WriteErrorResponse(w, http.StatusConflict, err.Error())
+	default:
+		response.WriteInternalServerError(w, "Failed to "+action+": "+err.Error())
+	}
+}
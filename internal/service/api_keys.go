@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang_daerah/pkg/jwtutil"
+	"strings"
+	"time"
+)
+
+// ErrAPIKeyNotFound is returned when revoking an unknown or already revoked key
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// apiKeyPrefix marks keys of this application so leaked keys are easy to search for
+const apiKeyPrefix = "gdk_"
+
+// lastUsedResolution limits how often last_used_at is written for a busy key
+const lastUsedResolution = time.Minute
+
+// APIKey is a stored key; the raw key is only returned once by CreateAPIKey
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	OwnerID    int        `json:"owner_id"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// NewAPIKey is the input of CreateAPIKey
+type NewAPIKey struct {
+	Name      string     `json:"name"`
+	OwnerID   int        `json:"owner_id"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is returned once after creation and carries the raw key
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+const apiKeyColumns = `id, name, owner_id, key_prefix, scopes, created_by, created_at, expires_at, last_used_at, revoked_at`
+
+func apiKeyFromRow(row map[string]interface{}) *APIKey {
+	key := &APIKey{
+		ID:        asInt64(row["id"]),
+		Name:      asString(row["name"]),
+		OwnerID:   int(asInt64(row["owner_id"])),
+		Prefix:    asString(row["key_prefix"]),
+		Scopes:    splitScopes(asString(row["scopes"])),
+		CreatedBy: asString(row["created_by"]),
+		CreatedAt: asTime(row["created_at"]),
+	}
+	for column, target := range map[string]**time.Time{
+		"expires_at":   &key.ExpiresAt,
+		"last_used_at": &key.LastUsedAt,
+		"revoked_at":   &key.RevokedAt,
+	} {
+		if row[column] != nil {
+			t := asTime(row[column])
+			*target = &t
+		}
+	}
+	return key
+}
+
+func splitScopes(scopes string) []string {
+	if scopes == "" {
+		return []string{}
+	}
+	return strings.Split(scopes, ",")
+}
+
+// CreateAPIKeyRecord stores a key and returns its id
+func (r *UserRepository) CreateAPIKeyRecord(ctx context.Context, key *APIKey, keyHash string) (int64, error) {
+	return r.InsertDBReturningIDContext(ctx, "default",
+		`INSERT INTO api_keys (name, owner_id, key_prefix, key_hash, scopes, created_by, created_at, expires_at)
+		 VALUES (:name, :owner_id, :key_prefix, :key_hash, :scopes, :created_by, :created_at, :expires_at)`,
+		map[string]interface{}{
+			"name":       key.Name,
+			"owner_id":   key.OwnerID,
+			"key_prefix": key.Prefix,
+			"key_hash":   keyHash,
+			"scopes":     strings.Join(key.Scopes, ","),
+			"created_by": key.CreatedBy,
+			"created_at": key.CreatedAt,
+			"expires_at": key.ExpiresAt,
+		})
+}
+
+// ListAPIKeys returns every key, newest first
+func (r *UserRepository) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	rows, err := r.QueryDBContext(ctx, "default", `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, apiKeyFromRow(row))
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey reports false when the key does not exist or was already revoked
+func (r *UserRepository) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	affected, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE api_keys SET revoked_at = :revoked_at WHERE id = :id AND revoked_at IS NULL`,
+		map[string]interface{}{"id": id, "revoked_at": time.Now().UTC()})
+	return affected > 0, err
+}
+
+// findActiveAPIKey returns the key with keyHash and the username of its owner, or nil when the
+// key is unknown, revoked, expired or belongs to a disabled user
+func (r *UserRepository) findActiveAPIKey(ctx context.Context, keyHash string, now time.Time) (*APIKey, string, error) {
+	rows, err := r.QueryDBContext(ctx, "default",
+		`SELECT k.id, k.name, k.owner_id, k.key_prefix, k.scopes, k.created_by, k.created_at,
+		        k.expires_at, k.last_used_at, k.revoked_at, u.username
+		 FROM api_keys k JOIN users u ON u.id = k.owner_id
+		 WHERE k.key_hash = ? AND k.revoked_at IS NULL AND u.disabled_at IS NULL
+		   AND (k.expires_at IS NULL OR k.expires_at > ?)`,
+		keyHash, now)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(rows) == 0 {
+		return nil, "", nil
+	}
+	return apiKeyFromRow(rows[0]), asString(rows[0]["username"]), nil
+}
+
+// touchAPIKey records the use of a key at most once per lastUsedResolution
+func (r *UserRepository) touchAPIKey(ctx context.Context, id int64, now time.Time) error {
+	_, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE api_keys SET last_used_at = :now
+		 WHERE id = :id AND (last_used_at IS NULL OR last_used_at < :stale_before)`,
+		map[string]interface{}{"id": id, "now": now, "stale_before": now.Add(-lastUsedResolution)})
+	return err
+}
+
+// APIKeyService manages API keys and authenticates X-API-Key requests.
+// It implements jwtutil.APIKeyAuthenticator.
+type APIKeyService struct {
+	Repo *UserRepository
+}
+
+func NewAPIKeyService(repo *UserRepository) *APIKeyService {
+	return &APIKeyService{Repo: repo}
+}
+
+// Create generates a key for input.OwnerID limited to input.Scopes. Every scope must be
+// granted to the owner through its roles.
+func (s *APIKeyService) Create(ctx context.Context, input NewAPIKey) (*CreatedAPIKey, error) {
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidPayload)
+	}
+	if len(input.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidPayload)
+	}
+	for _, scope := range input.Scopes {
+		if !jwtutil.IsKnownPermission(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidPayload, scope)
+		}
+	}
+	now := time.Now().UTC()
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidPayload)
+	}
+
+	owner, err := s.Repo.GetUserByID(ctx, input.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, ErrUserNotFound
+	}
+	// A key acts as its owner, so it must not grant more than the owner's roles do
+	_, permissions, err := s.Repo.loadAccess(ctx, owner.ID)
+	if err != nil {
+		return nil, err
+	}
+	ownerAccess := &jwtutil.Principal{Permissions: permissions}
+	for _, scope := range input.Scopes {
+		if !ownerAccess.HasPermission(scope) {
+			return nil, fmt.Errorf("%w: owner %s does not have the %q permission", ErrInvalidPayload, owner.Username, scope)
+		}
+	}
+
+	rawKey, err := newAPIKey()
+	if err != nil {
+		return nil, errors.New("failed to generate api key")
+	}
+
+	key := &APIKey{
+		Name:      input.Name,
+		OwnerID:   owner.ID,
+		Prefix:    rawKey[:len(apiKeyPrefix)+8],
+		Scopes:    input.Scopes,
+		CreatedBy: jwtutil.UsernameFromContext(ctx),
+		CreatedAt: now,
+		ExpiresAt: input.ExpiresAt,
+	}
+	key.ID, err = s.Repo.CreateAPIKeyRecord(ctx, key, hashToken(rawKey))
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: key, Key: rawKey}, nil
+}
+
+// List returns every key without the secret
+func (s *APIKeyService) List(ctx context.Context) ([]*APIKey, error) {
+	return s.Repo.ListAPIKeys(ctx)
+}
+
+// Revoke disables a key immediately
+func (s *APIKeyService) Revoke(ctx context.Context, id int64) error {
+	revoked, err := s.Repo.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves rawKey to a principal acting as the key owner with the key scopes
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, rawKey string) (*jwtutil.Principal, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, jwtutil.ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	key, ownerName, err := s.Repo.findActiveAPIKey(ctx, hashToken(rawKey), now)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, jwtutil.ErrInvalidAPIKey
+	}
+
+	if err := s.Repo.touchAPIKey(ctx, key.ID, now); err != nil {
+		return nil, err
+	}
+
+	return &jwtutil.Principal{
+		UserID:      key.OwnerID,
+		Username:    ownerName,
+		Permissions: key.Scopes,
+		APIKeyID:    key.ID,
+	}, nil
+}
+
+// newAPIKey returns a random key such as gdk_3q2...; 32 random bytes make a plain SHA-256
+// hash safe to store
+func newAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"context"
+	"golang_daerah/pkg/jwtutil"
+)
+
+// actor returns the value stored in created_by, updated_by and deleted_by for the caller of ctx.
+// Writes made without an authenticated principal store NULL.
+func actor(ctx context.Context) interface{} {
+	if username := jwtutil.UsernameFromContext(ctx); username != "" {
+		return username
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang_daerah/internal/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrInvalidPayload is returned when a create request body is not a JSON array of objects
+var ErrInvalidPayload = errors.New("invalid request payload")
+
+// Per-item outcomes reported by bulk creates
+const (
+	BulkStatusCreated    = "created"
+	BulkStatusUpdated    = "updated"
+	BulkStatusFailed     = "failed"
+	BulkStatusRolledBack = "rolled_back"
+	BulkStatusSkipped    = "skipped"
+)
+
+// BulkItemResult describes what happened to one element of a bulk create request
+type BulkItemResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     int64  `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkResult is the outcome of a bulk create, one entry per input index
+type BulkResult struct {
+	Atomic    bool             `json:"atomic"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Items     []BulkItemResult `json:"items"`
+}
+
+// bulkWriter saves one item and returns its id and whether it was created or updated
+type bulkWriter func(ctx context.Context, ext sqlx.ExtContext, item map[string]interface{}) (int64, string, error)
+
+// insertWriter saves every item as a new row with the given named INSERT
+func insertWriter(query string) bulkWriter {
+	return func(ctx context.Context, ext sqlx.ExtContext, item map[string]interface{}) (int64, string, error) {
+		id, err := database.InsertReturningID(ctx, ext, query, item)
+		return id, BulkStatusCreated, err
+	}
+}
+
+// upsertWriter inserts or updates rows of table matched on its natural key
+func upsertWriter(t resourceTable) bulkWriter {
+	return func(ctx context.Context, ext sqlx.ExtContext, item map[string]interface{}) (int64, string, error) {
+		for _, key := range t.naturalKey {
+			if item[key] == nil {
+				return 0, "", fmt.Errorf("natural key field %q is required for upsert", key)
+			}
+		}
+		columns := append(append([]string{}, t.columns...), "created_by", "updated_by")
+		id, inserted, err := database.UpsertReturningID(ctx, ext, t.table, columns, t.naturalKey, []string{"created_by"}, item)
+		if inserted {
+			return id, BulkStatusCreated, err
+		}
+		return id, BulkStatusUpdated, err
+	}
+}
+
+// parseBulkItems decodes the request body shared by every Create endpoint
+func parseBulkItems(jsonData []byte) ([]map[string]interface{}, error) {
+	var items []map[string]interface{}
+	if err := json.Unmarshal(jsonData, &items); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: at least one item is required", ErrInvalidPayload)
+	}
+	return items, nil
+}
+
+// createBulk saves every item with write, recording the caller of ctx as created_by and updated_by.
+// With atomic set, all items share one transaction and a single failure rolls back the batch.
+// Otherwise each item is saved on its own and failures do not affect the others.
+func createBulk(ctx context.Context, db *database.BaseMultiDBRepository, dbName string, items []map[string]interface{}, atomic bool, write bulkWriter) (*BulkResult, error) {
+	result := &BulkResult{
+		Atomic: atomic,
+		Items:  make([]BulkItemResult, len(items)),
+	}
+	by := actor(ctx)
+	for i, item := range items {
+		result.Items[i] = BulkItemResult{Index: i}
+		item["created_by"] = by
+		item["updated_by"] = by
+	}
+
+	if !atomic {
+		for i, item := range items {
+			err := db.WithConnContext(ctx, dbName, func(ctx context.Context, ext sqlx.ExtContext) error {
+				id, status, err := write(ctx, ext, item)
+				if err != nil {
+					return err
+				}
+				result.Items[i].Status = status
+				result.Items[i].ID = id
+				return nil
+			})
+			if err != nil {
+				result.Items[i].Status = BulkStatusFailed
+				result.Items[i].Error = err.Error()
+			}
+		}
+		result.count()
+		return result, nil
+	}
+
+	failedAt := -1
+	err := db.WithTxContext(ctx, dbName, func(ctx context.Context, tx *sqlx.Tx) error {
+		for i, item := range items {
+			id, status, err := write(ctx, tx, item)
+			if err != nil {
+				failedAt = i
+				return err
+			}
+			result.Items[i].Status = status
+			result.Items[i].ID = id
+		}
+		return nil
+	})
+	if err != nil {
+		if failedAt < 0 {
+			// The transaction itself failed (begin/commit), nothing was saved
+			return nil, err
+		}
+		for i := range result.Items {
+			switch {
+			case i < failedAt:
+				result.Items[i].Status = BulkStatusRolledBack
+				result.Items[i].ID = 0
+			case i == failedAt:
+				result.Items[i].Status = BulkStatusFailed
+				result.Items[i].Error = err.Error()
+			default:
+				result.Items[i].Status = BulkStatusSkipped
+			}
+		}
+	}
+
+	result.count()
+	return result, nil
+}
+
+func (b *BulkResult) count() {
+	b.Succeeded, b.Failed, b.Created, b.Updated = 0, 0, 0, 0
+	for _, item := range b.Items {
+		switch item.Status {
+		case BulkStatusCreated:
+			b.Created++
+			b.Succeeded++
+		case BulkStatusUpdated:
+			b.Updated++
+			b.Succeeded++
+		default:
+			b.Failed++
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"golang_daerah/internal/database"
+	"golang_daerah/pkg/middleware"
+	"time"
+)
+
+// IdempotencyStore persists Idempotency-Key responses in the golang database.
+// It implements middleware.IdempotencyStore.
+type IdempotencyStore struct {
+	db *database.BaseMultiDBRepository
+}
+
+func NewIdempotencyStore(db *database.BaseMultiDBRepository) *IdempotencyStore {
+	return &IdempotencyStore{db: db}
+}
+
+// Reserve claims key for owner. When the key is already known and younger than ttl,
+// the stored record is returned and reserved is false.
+func (s *IdempotencyStore) Reserve(owner, key, requestHash string, ttl time.Duration) (*middleware.IdempotencyRecord, bool, error) {
+	now := time.Now().UTC()
+
+	// An expired key behaves as if it was never used
+	if _, err := s.db.DeleteDB("golang",
+		`DELETE FROM idempotency_keys WHERE username = ? AND idempotency_key = ? AND created_at < ?`,
+		owner, key, now.Add(-ttl)); err != nil {
+		return nil, false, err
+	}
+
+	if record, err := s.find(owner, key); err != nil || record != nil {
+		return record, false, err
+	}
+
+	err := s.db.InsertDB("golang",
+		`INSERT INTO idempotency_keys (username, idempotency_key, request_hash, status_code, created_at)
+		 VALUES (:username, :idempotency_key, :request_hash, 0, :created_at)`,
+		map[string]interface{}{
+			"username":        owner,
+			"idempotency_key": key,
+			"request_hash":    requestHash,
+			"created_at":      now,
+		})
+	if err != nil {
+		// Lost the race against a concurrent request with the same key
+		if record, findErr := s.find(owner, key); findErr == nil && record != nil {
+			return record, false, nil
+		}
+		return nil, false, err
+	}
+
+	return nil, true, nil
+}
+
+// Complete stores the final response for a reserved key
+func (s *IdempotencyStore) Complete(owner, key string, statusCode int, body []byte) error {
+	_, err := s.db.UpdateDB("golang",
+		`UPDATE idempotency_keys SET status_code = :status_code, response_body = :response_body
+		 WHERE username = :username AND idempotency_key = :idempotency_key`,
+		map[string]interface{}{
+			"status_code":     statusCode,
+			"response_body":   string(body),
+			"username":        owner,
+			"idempotency_key": key,
+		})
+	return err
+}
+
+// Release drops a reservation so the client can retry with the same key
+func (s *IdempotencyStore) Release(owner, key string) error {
+	_, err := s.db.DeleteDB("golang",
+		`DELETE FROM idempotency_keys WHERE username = ? AND idempotency_key = ?`,
+		owner, key)
+	return err
+}
+
+// PurgeExpired removes every key older than ttl
+func (s *IdempotencyStore) PurgeExpired(ttl time.Duration) error {
+	_, err := s.db.DeleteDB("golang",
+		`DELETE FROM idempotency_keys WHERE created_at < ?`,
+		time.Now().UTC().Add(-ttl))
+	return err
+}
+
+func (s *IdempotencyStore) find(owner, key string) (*middleware.IdempotencyRecord, error) {
+	rows, err := s.db.QueryDB("golang",
+		`SELECT request_hash, status_code, response_body, created_at
+		 FROM idempotency_keys WHERE username = ? AND idempotency_key = ?`,
+		owner, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	row := rows[0]
+	return &middleware.IdempotencyRecord{
+		RequestHash: asString(row["request_hash"]),
+		StatusCode:  int(asInt64(row["status_code"])),
+		Body:        []byte(asString(row["response_body"])),
+		CreatedAt:   asTime(row["created_at"]),
+	}, nil
+}
@@ -1,15 +1,34 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"golang_daerah/internal/database"
 	"fmt"
+	"sort"
 )
 
 type LautService struct {
 	db *database.BaseMultiDBRepository
 }
 
+// lautColumns are the columns clients may write
+var lautColumns = []string{
+	"port_name", "port_code", "port_address", "city",
+	"province", "country", "operator_name", "operator_contact",
+	"harbor_master_name", "harbor_master_id", "harbor_master_rank", "harbor_master_office_address",
+	"number_of_piers", "main_pier_length", "max_ship_draft", "max_ship_length",
+	"terminal_capacity_passenger", "terminal_capacity_cargo", "operational_hours", "emergency_contact",
+	"security_office_name", "security_officer_id", "security_level", "checkin_counter_count",
+	"special_facilities",
+}
+
+var lautTable = resourceTable{
+	dbName:     "terminal",
+	table:      "Laut",
+	columns:    lautColumns,
+	naturalKey: []string{"port_code"},
+}
+
 // var joinQueries = map[string]string{
 // 	"passenger": `SELECT passenger_name FROM passenger_plane WHERE id = ?`,
 // 	"traffic":   `SELECT violation_location FROM traffic_tickets WHERE id = ?`,
@@ -75,10 +94,10 @@ func NewLautService(db *database.BaseMultiDBRepository) *LautService {
 //	}
 //
 // --------------------------------------------------------------------------------------------
-func (r *LautService) Create(jsonData []byte) error {
-	var items []map[string]interface{}
-	if err := json.Unmarshal(jsonData, &items); err != nil {
-		return err
+func (r *LautService) Create(ctx context.Context, jsonData []byte, atomic bool) (*BulkResult, error) {
+	items, err := parseBulkItems(jsonData)
+	if err != nil {
+		return nil, err
 	}
 
 	query := `
@@ -89,7 +108,7 @@ func (r *LautService) Create(jsonData []byte) error {
             main_pier_length, max_ship_draft, max_ship_length,
             terminal_capacity_passenger, terminal_capacity_cargo, operational_hours,
             emergency_contact, security_office_name, security_officer_id,
-            security_level, checkin_counter_count, special_facilities
+            security_level, checkin_counter_count, special_facilities, created_by, updated_by
         ) VALUES (
             :port_name, :port_code, :port_address, :city, :province, :country,
             :operator_name, :operator_contact, :harbor_master_name, :harbor_master_id,
@@ -97,14 +116,13 @@ func (r *LautService) Create(jsonData []byte) error {
             :main_pier_length, :max_ship_draft, :max_ship_length,
             :terminal_capacity_passenger, :terminal_capacity_cargo, :operational_hours,
             :emergency_contact, :security_office_name, :security_officer_id,
-            :security_level, :checkin_counter_count, :special_facilities
+            :security_level, :checkin_counter_count, :special_facilities, :created_by, :updated_by
         )
     `
 
-	for _, item := range items {
-		if err := r.db.InsertDB("terminal", query, item); err != nil {
-			return err
-		}
+	result, err := createBulk(ctx, r.db, "terminal", items, atomic, insertWriter(query))
+	if err != nil {
+		return nil, err
 	}
 	// for _, item := range items {
 	// 	// Insert into passenger database
@@ -134,10 +152,44 @@ func (r *LautService) Create(jsonData []byte) error {
 	// 		`INSERT INTO users (username, port_id) VALUES (:username, :port_id)`,
 	// 		userData)
 	// }
-	return nil
+	return result, nil
+}
+
+// Upsert inserts new ports and updates existing ones matched on port_code
+func (r *LautService) Upsert(ctx context.Context, jsonData []byte, atomic bool) (*BulkResult, error) {
+	items, err := parseBulkItems(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	return createBulk(ctx, r.db, "terminal", items, atomic, upsertWriter(lautTable))
+}
+
+// Get returns a single port with its version
+func (r *LautService) Get(ctx context.Context, id int64, includeDeleted bool) (map[string]interface{}, error) {
+	return lautTable.getByID(ctx, r.db, id, includeDeleted)
+}
+
+// Update changes the given fields when version still matches and returns the new version
+func (r *LautService) Update(ctx context.Context, id, version int64, jsonData []byte) (int64, error) {
+	return lautTable.update(ctx, r.db, id, version, jsonData)
+}
+
+// Delete soft deletes a port when version still matches, recording the caller as deleted_by
+func (r *LautService) Delete(ctx context.Context, id, version int64) error {
+	return lautTable.softDelete(ctx, r.db, id, version)
 }
 
-func (r *LautService) GetPaginated(limit, offset int) ([]map[string]interface{}, error) {
+// Restore undoes a soft delete
+func (r *LautService) Restore(ctx context.Context, id int64) (bool, error) {
+	return lautTable.restore(ctx, r.db, id)
+}
+
+// Purge permanently removes a soft deleted port
+func (r *LautService) Purge(ctx context.Context, id int64) (bool, error) {
+	return lautTable.purge(ctx, r.db, id)
+}
+
+func (r *LautService) GetPaginated(ctx context.Context, limit, offset int, includeDeleted bool) ([]map[string]interface{}, error) {
 	// ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
 	// defer cancel()
 	// db := r.getDB(dbName)
@@ -148,29 +200,31 @@ func (r *LautService) GetPaginated(limit, offset int) ([]map[string]interface{},
                main_pier_length, max_ship_draft, max_ship_length,
                terminal_capacity_passenger, terminal_capacity_cargo, operational_hours,
                emergency_contact, security_office_name, security_officer_id,
-               security_level, checkin_counter_count, special_facilities
+               security_level, checkin_counter_count, special_facilities,
+               version, created_by, updated_by, deleted_at, deleted_by
         FROM Laut
+        WHERE ` + liveRowsOnly(includeDeleted) + `
         ORDER BY id ASC
         LIMIT ? OFFSET ?
     `
-	result, err := r.db.QueryDB("terminal", query, limit, offset)
+	result, err := r.db.QueryDBContext(ctx, "terminal", query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	//this for multiple db query
 	for i, port := range result {
 		// Database 2: Passengers
-		passengers, _ := r.db.QueryDB("passenger",
-			`SELECT passenger_name FROM passenger_plane WHERE id = ?`,
+		passengers, _ := r.db.QueryDBContext(ctx, "passenger",
+			`SELECT passenger_name FROM passenger_plane WHERE id = ? AND deleted_at IS NULL`,
 			port["id"])
 
 		// Database 3: Traffic tickets
-		tickets, _ := r.db.QueryDB("traffic",
-			`SELECT legal_speed FROM traffic_tickets WHERE id = ?`,
+		tickets, _ := r.db.QueryDBContext(ctx, "traffic",
+			`SELECT legal_speed FROM traffic_tickets WHERE id = ? AND deleted_at IS NULL`,
 			port["id"])
 
 		// Database 4: Auth/Users (if needed)
-		users, _ := r.db.QueryDB("golang",
+		users, _ := r.db.QueryDBContext(ctx, "golang",
 			`SELECT username FROM users WHERE id = ?`,
 			port["id"])
 
@@ -226,10 +280,10 @@ func (r *LautService) GetPaginated(limit, offset int) ([]map[string]interface{},
 	// return json.Marshal(results)
 }
 
-func (r *LautService) GetCompleteData(limit, offset int) ([]map[string]interface{}, error) {
+func (r *LautService) GetCompleteData(ctx context.Context, limit, offset int, includeDeleted bool) ([]map[string]interface{}, error) {
 	// Database 1: Ports
-	ports, err := r.db.QueryDB("terminal",
-		`SELECT id, port_name FROM Laut LIMIT ? OFFSET ?`,
+	ports, err := r.db.QueryDBContext(ctx, "terminal",
+		`SELECT id, port_name FROM Laut WHERE `+liveRowsOnly(includeDeleted)+` LIMIT ? OFFSET ?`,
 		limit, offset)
 	if err != nil {
 		return nil, err
@@ -237,17 +291,17 @@ func (r *LautService) GetCompleteData(limit, offset int) ([]map[string]interface
 
 	for i, port := range ports {
 		// Database 2: Passengers
-		passengers, _ := r.db.QueryDB("passenger",
-			`SELECT passenger_name FROM passenger_plane WHERE id = ?`,
+		passengers, _ := r.db.QueryDBContext(ctx, "passenger",
+			`SELECT passenger_name FROM passenger_plane WHERE id = ? AND deleted_at IS NULL`,
 			port["id"])
 
 		// Database 3: Traffic tickets
-		tickets, _ := r.db.QueryDB("traffic",
-			`SELECT legal_speed FROM traffic_tickets WHERE id = ?`,
+		tickets, _ := r.db.QueryDBContext(ctx, "traffic",
+			`SELECT legal_speed FROM traffic_tickets WHERE id = ? AND deleted_at IS NULL`,
 			port["id"])
 
 		// Database 4: Auth/Users (if needed)
-		users, _ := r.db.QueryDB("golang",
+		users, _ := r.db.QueryDBContext(ctx, "golang",
 			`SELECT username FROM users WHERE id = ?`,
 			port["id"])
 
@@ -375,48 +429,54 @@ func (r *LautService) GetCompleteData(limit, offset int) ([]map[string]interface
 // 	return json.Marshal(results)
 // }
 
-// internal/service/traffic_ticket_sqlx_handler.go
-func (r *LautService) GetPaginatedWithFilters(limit, offset int, filters map[string]string) ([]map[string]interface{}, error) {
-    query := `
-        SELECT id, detected_speed as kecepatan, legal_speed, violation_location, 
-               violation_date, violation_time, violation_type, 
-               license_plate_number, vehicle_production_id, vehicle_factory,
-               vehicle_model, vehicle_color, vehicle_brand, officer_name,
-               officer_id, officer_rank, suspect_name, suspect_id, 
-               suspect_age, officer_age, suspect_job, suspect_address,
-               suspect_birth_place, officer_branch_office_address
-        FROM traffic_tickets
-        WHERE 1=1
-    `
-    
-    args := []interface{}{}
-    
-    // Add dynamic filters for ANY column
-    for column, value := range filters {
-        // Security: Whitelist allowed columns (prevent SQL injection)
-        // allowedColumns := map[string]bool{
-		// 	"id": true,
-        //     "violation_location": true,
-        //     "violation_type": true,
-        //     "license_plate_number": true,
-        //     "vehicle_color": true,
-        //     "vehicle_brand": true,
-		// 	"vehicle_model": true,
-        //     "suspect_name": true,
-        //     "officer_name": true,
-        //     "suspect_job": true,
-        //     "suspect_address": true,
-        //     "suspect_birth_place": true,
-        // }
-        
-        // if allowedColumns[column] {
-            query += fmt.Sprintf(" AND %s = ?", column)
-            args = append(args, value)
-        // }
-    }
-    
-    query += " ORDER BY id ASC LIMIT ? OFFSET ?"
-    args = append(args, limit, offset)
-
-    return r.db.QueryDB("traffic", query, args...)
+// lautFilterColumns are the query parameters GetPaginatedWithFilters accepts as column filters
+var lautFilterColumns = map[string]bool{
+	"id":                 true,
+	"port_name":          true,
+	"port_code":          true,
+	"city":               true,
+	"province":           true,
+	"country":            true,
+	"operator_name":      true,
+	"harbor_master_name": true,
+	"harbor_master_id":   true,
+	"security_level":     true,
+}
+
+// GetPaginatedWithFilters lists ports matching every filter (column = value).
+// A filter on a column outside lautFilterColumns is rejected with ErrInvalidPayload.
+func (r *LautService) GetPaginatedWithFilters(ctx context.Context, limit, offset int, filters map[string]string, includeDeleted bool) ([]map[string]interface{}, error) {
+	query := `
+        SELECT id, port_name, port_code, port_address, city, province, country,
+               operator_name, operator_contact, harbor_master_name, harbor_master_id,
+               harbor_master_rank, harbor_master_office_address, number_of_piers,
+               main_pier_length, max_ship_draft, max_ship_length,
+               terminal_capacity_passenger, terminal_capacity_cargo, operational_hours,
+               emergency_contact, security_office_name, security_officer_id,
+               security_level, checkin_counter_count, special_facilities,
+               version, created_by, updated_by, deleted_at, deleted_by
+        FROM Laut
+        WHERE ` + liveRowsOnly(includeDeleted)
+
+	// Column names cannot be bound, so only allowlisted names reach the SQL text.
+	// Sorted so the statement text is stable.
+	columns := make([]string, 0, len(filters))
+	for column := range filters {
+		if !lautFilterColumns[column] {
+			return nil, fmt.Errorf("%w: unknown filter %q", ErrInvalidPayload, column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	args := []interface{}{}
+	for _, column := range columns {
+		query += " AND " + column + " = ?"
+		args = append(args, filters[column])
+	}
+
+	query += " ORDER BY id ASC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	return r.db.QueryDBContext(ctx, "terminal", query, args...)
 }
\ No newline at end of file
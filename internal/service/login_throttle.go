@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang_daerah/config"
+	"golang_daerah/internal/database"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrInvalidCredentials is returned for an unknown username or a wrong password
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// LoginThrottledError is returned while an account or client IP has to wait before the next login attempt
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // true for a lockout, false for a progressive delay
+}
+
+func (e *LoginThrottledError) Error() string {
+	seconds := int(e.RetryAfter.Round(time.Second) / time.Second)
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins, try again in %d seconds", seconds)
+	}
+	return fmt.Sprintf("login attempted too soon after a failure, try again in %d seconds", seconds)
+}
+
+// Scopes of the login_failures table
+const (
+	throttleScopeAccount = "account"
+	throttleScopeIP      = "ip"
+)
+
+// loginFailure is one row of login_failures
+type loginFailure struct {
+	Failures      int
+	FirstFailedAt time.Time
+	LastFailedAt  time.Time
+	LockedUntil   *time.Time
+}
+
+// getLoginFailure returns nil when subject has no recorded failures
+func (r *UserRepository) getLoginFailure(ctx context.Context, scope, subject string) (*loginFailure, error) {
+	rows, err := r.QueryDBContext(ctx, "default",
+		`SELECT failures, first_failed_at, last_failed_at, locked_until
+		 FROM login_failures WHERE scope = ? AND subject = ?`,
+		scope, subject)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	row := rows[0]
+	failure := &loginFailure{
+		Failures:      int(asInt64(row["failures"])),
+		FirstFailedAt: asTime(row["first_failed_at"]),
+		LastFailedAt:  asTime(row["last_failed_at"]),
+	}
+	if row["locked_until"] != nil {
+		lockedUntil := asTime(row["locked_until"])
+		failure.LockedUntil = &lockedUntil
+	}
+	return failure, nil
+}
+
+// recordLoginFailure counts one failed login of subject. The row is locked while next computes
+// the new counters from the stored ones (nil for a first failure), so concurrent failures are
+// all counted instead of overwriting each other.
+func (r *UserRepository) recordLoginFailure(ctx context.Context, scope, subject string, now time.Time, next func(stored *loginFailure) *loginFailure) error {
+	key := map[string]interface{}{"scope": scope, "subject": subject, "now": now}
+	return r.WithTxContext(ctx, "default", func(ctx context.Context, tx *sqlx.Tx) error {
+		// Make sure there is a row to lock; other requests only see it with the counters below
+		insert := `INSERT INTO login_failures (scope, subject, failures, first_failed_at, last_failed_at)
+			 VALUES (:scope, :subject, 0, :now, :now)`
+		if r.dialect == "mysql" {
+			insert = `INSERT IGNORE INTO login_failures (scope, subject, failures, first_failed_at, last_failed_at)
+			 VALUES (:scope, :subject, 0, :now, :now)`
+		} else {
+			insert += ` ON CONFLICT DO NOTHING`
+		}
+		if _, err := database.NamedExec(ctx, tx, insert, key); err != nil {
+			return err
+		}
+
+		stored := &loginFailure{}
+		err := tx.QueryRowxContext(ctx, tx.Rebind(
+			`SELECT failures, first_failed_at, last_failed_at, locked_until
+			 FROM login_failures WHERE scope = ? AND subject = ? FOR UPDATE`), scope, subject).
+			Scan(&stored.Failures, &stored.FirstFailedAt, &stored.LastFailedAt, &stored.LockedUntil)
+		if err != nil {
+			return database.HandleQueryError(err)
+		}
+		if stored.Failures == 0 {
+			stored = nil
+		}
+
+		failure := next(stored)
+		_, err = database.NamedExec(ctx, tx,
+			`UPDATE login_failures
+			 SET failures = :failures, first_failed_at = :first_failed_at,
+			     last_failed_at = :last_failed_at, locked_until = :locked_until
+			 WHERE scope = :scope AND subject = :subject`,
+			map[string]interface{}{
+				"scope":           scope,
+				"subject":         subject,
+				"failures":        failure.Failures,
+				"first_failed_at": failure.FirstFailedAt,
+				"last_failed_at":  failure.LastFailedAt,
+				"locked_until":    failure.LockedUntil,
+			})
+		return err
+	})
+}
+
+// clearLoginFailures forgets the failures of subject
+func (r *UserRepository) clearLoginFailures(ctx context.Context, scope, subject string) error {
+	_, err := r.DeleteDBContext(ctx, "default",
+		`DELETE FROM login_failures WHERE scope = ? AND subject = ?`,
+		scope, subject)
+	return err
+}
+
+// LoginThrottle slows down repeated failed logins per account and per client IP.
+// After each failure the next attempt has to wait BaseDelay, doubled for every further
+// failure up to MaxDelay; reaching the failure limit locks the account or IP for LockoutDuration.
+type LoginThrottle struct {
+	repo     *UserRepository
+	settings config.LoginThrottleSettings
+	now      func() time.Time
+}
+
+func NewLoginThrottle(repo *UserRepository, settings config.LoginThrottleSettings) *LoginThrottle {
+	return &LoginThrottle{repo: repo, settings: settings, now: time.Now}
+}
+
+// Check returns a *LoginThrottledError when username or ip must wait before trying again
+func (t *LoginThrottle) Check(ctx context.Context, username, ip string) error {
+	var worst *LoginThrottledError
+	for _, s := range t.subjects(username, ip) {
+		failure, err := t.repo.getLoginFailure(ctx, s.scope, s.subject)
+		if err != nil {
+			return err
+		}
+		if wait := t.wait(failure); wait != nil && (worst == nil || wait.RetryAfter > worst.RetryAfter) {
+			worst = wait
+		}
+	}
+	if worst != nil {
+		return worst
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against username and ip
+func (t *LoginThrottle) RecordFailure(ctx context.Context, username, ip string) error {
+	now := t.now().UTC()
+	for _, s := range t.subjects(username, ip) {
+		err := t.repo.recordLoginFailure(ctx, s.scope, s.subject, now, func(failure *loginFailure) *loginFailure {
+			if t.expired(failure, now) {
+				failure = &loginFailure{FirstFailedAt: now}
+			}
+			failure.Failures++
+			failure.LastFailedAt = now
+			if failure.Failures >= s.maxFailures {
+				lockedUntil := now.Add(t.settings.LockoutDuration)
+				failure.LockedUntil = &lockedUntil
+			}
+			return failure
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordSuccess resets the failures of an account. Failures of the IP are kept so that
+// one valid account cannot be used to reset a password spraying run.
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, username string) error {
+	return t.repo.clearLoginFailures(ctx, throttleScopeAccount, username)
+}
+
+// Unlock lifts a lockout of username before it expires
+func (t *LoginThrottle) Unlock(ctx context.Context, username string) error {
+	return t.repo.clearLoginFailures(ctx, throttleScopeAccount, username)
+}
+
+type throttleSubject struct {
+	scope       string
+	subject     string
+	maxFailures int
+}
+
+func (t *LoginThrottle) subjects(username, ip string) []throttleSubject {
+	var subjects []throttleSubject
+	if username != "" {
+		subjects = append(subjects, throttleSubject{throttleScopeAccount, username, t.settings.AccountMaxFailures})
+	}
+	if ip != "" {
+		subjects = append(subjects, throttleSubject{throttleScopeIP, ip, t.settings.IPMaxFailures})
+	}
+	return subjects
+}
+
+// expired reports whether a row no longer counts: no row, a lockout that ran out, or
+// failures older than the window
+func (t *LoginThrottle) expired(failure *loginFailure, now time.Time) bool {
+	if failure == nil {
+		return true
+	}
+	if failure.LockedUntil != nil {
+		return !now.Before(*failure.LockedUntil)
+	}
+	return now.Sub(failure.FirstFailedAt) > t.settings.Window
+}
+
+// wait returns how long the subject of failure still has to wait, or nil
+func (t *LoginThrottle) wait(failure *loginFailure) *LoginThrottledError {
+	now := t.now().UTC()
+	if t.expired(failure, now) {
+		return nil
+	}
+	if failure.LockedUntil != nil {
+		return &LoginThrottledError{RetryAfter: failure.LockedUntil.Sub(now), Locked: true}
+	}
+
+	next := failure.LastFailedAt.Add(t.delay(failure.Failures))
+	if now.Before(next) {
+		return &LoginThrottledError{RetryAfter: next.Sub(now)}
+	}
+	return nil
+}
+
+// delay is BaseDelay doubled for every failure after the first, capped at MaxDelay
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	d := t.settings.BaseDelay
+	for i := 1; i < failures && d < t.settings.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > t.settings.MaxDelay {
+		d = t.settings.MaxDelay
+	}
+	return d
+}
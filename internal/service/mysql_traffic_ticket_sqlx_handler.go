@@ -1,7 +1,7 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"golang_daerah/internal/database"
 )
 
@@ -9,6 +9,13 @@ type MySQLTrafficTicketService struct {
 	db *database.BaseMultiDBRepository
 }
 
+var mysqlTrafficTable = resourceTable{
+	dbName:     "mysql",
+	table:      "traffic_tickets",
+	columns:    trafficTicketColumns,
+	naturalKey: []string{"license_plate_number", "violation_date", "violation_time"},
+}
+
 func NewMySQLTrafficTicketService(db *database.BaseMultiDBRepository) *MySQLTrafficTicketService {
 	return &MySQLTrafficTicketService{
 		db: db,
@@ -49,7 +56,7 @@ func NewMySQLTrafficTicketService(db *database.BaseMultiDBRepository) *MySQLTraf
 // 	return r.dbs["default"]
 // }
 
-func (r *MySQLTrafficTicketService) GetPaginated(limit, offset int) ([]map[string]interface{}, error) {
+func (r *MySQLTrafficTicketService) GetPaginated(ctx context.Context, limit, offset int, includeDeleted bool) ([]map[string]interface{}, error) {
 	// ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
 	// defer cancel()
 	// db := r.getDB(dbName)
@@ -60,13 +67,15 @@ func (r *MySQLTrafficTicketService) GetPaginated(limit, offset int) ([]map[strin
                vehicle_model, vehicle_color, vehicle_brand, officer_name,
                officer_id, officer_rank, suspect_name, suspect_id, 
                suspect_age, officer_age, suspect_job, suspect_address,
-               suspect_birth_place, officer_branch_office_address
+               suspect_birth_place, officer_branch_office_address,
+               version, created_by, updated_by, deleted_at, deleted_by
         FROM traffic_tickets
+        WHERE ` + liveRowsOnly(includeDeleted) + `
         ORDER BY id ASC
         LIMIT ? OFFSET ?
     `
 
-	result, err := r.db.QueryDB("mysql", query, limit, offset)
+	result, err := r.db.QueryDBContext(ctx, "mysql", query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -141,13 +150,13 @@ func (r *MySQLTrafficTicketService) GetPaginated(limit, offset int) ([]map[strin
 	// return json.Marshal(results)
 }
 
-func (r *MySQLTrafficTicketService) Create(jsonData []byte) error {
+func (r *MySQLTrafficTicketService) Create(ctx context.Context, jsonData []byte, atomic bool) (*BulkResult, error) {
 	// ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
 	// defer cancel()
 	// db := r.getDB(dbName)
-	var items []map[string]interface{}
-	if err := json.Unmarshal(jsonData, &items); err != nil {
-		return err
+	items, err := parseBulkItems(jsonData)
+	if err != nil {
+		return nil, err
 	}
 
 	query := `
@@ -157,21 +166,20 @@ func (r *MySQLTrafficTicketService) Create(jsonData []byte) error {
             vehicle_production_id, vehicle_factory, vehicle_model, vehicle_color,
             vehicle_brand, officer_name, officer_id, officer_rank, suspect_name,
             suspect_id, suspect_age, officer_age, suspect_job, suspect_address,
-            suspect_birth_place, officer_branch_office_address
+            suspect_birth_place, officer_branch_office_address, created_by, updated_by
         ) VALUES (
             :detected_speed, :legal_speed, :violation_location, :violation_date,
             :violation_time, :violation_type, :license_plate_number,
             :vehicle_production_id, :vehicle_factory, :vehicle_model, :vehicle_color,
             :vehicle_brand, :officer_name, :officer_id, :officer_rank, :suspect_name,
             :suspect_id, :suspect_age, :officer_age, :suspect_job, :suspect_address,
-            :suspect_birth_place, :officer_branch_office_address
+            :suspect_birth_place, :officer_branch_office_address, :created_by, :updated_by
         )
     `
 
-	for _, item := range items {
-		if err := r.db.InsertDB("mysql", query, item); err != nil {
-			return err
-		}
+	result, err := createBulk(ctx, r.db, "mysql", items, atomic, insertWriter(query))
+	if err != nil {
+		return nil, err
 	}
 	// for _, item := range items {
 	// 	// Insert into passenger database
@@ -202,7 +210,41 @@ func (r *MySQLTrafficTicketService) Create(jsonData []byte) error {
 	// 		userData)
 	// }
 
-	return nil
+	return result, nil
+}
+
+// Upsert inserts new tickets and updates existing ones matched on license_plate_number + violation_date + violation_time
+func (r *MySQLTrafficTicketService) Upsert(ctx context.Context, jsonData []byte, atomic bool) (*BulkResult, error) {
+	items, err := parseBulkItems(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	return createBulk(ctx, r.db, "mysql", items, atomic, upsertWriter(mysqlTrafficTable))
+}
+
+// Get returns a single ticket with its version
+func (r *MySQLTrafficTicketService) Get(ctx context.Context, id int64, includeDeleted bool) (map[string]interface{}, error) {
+	return mysqlTrafficTable.getByID(ctx, r.db, id, includeDeleted)
+}
+
+// Update changes the given fields when version still matches and returns the new version
+func (r *MySQLTrafficTicketService) Update(ctx context.Context, id, version int64, jsonData []byte) (int64, error) {
+	return mysqlTrafficTable.update(ctx, r.db, id, version, jsonData)
+}
+
+// Delete soft deletes a ticket when version still matches, recording the caller as deleted_by
+func (r *MySQLTrafficTicketService) Delete(ctx context.Context, id, version int64) error {
+	return mysqlTrafficTable.softDelete(ctx, r.db, id, version)
+}
+
+// Restore undoes a soft delete
+func (r *MySQLTrafficTicketService) Restore(ctx context.Context, id int64) (bool, error) {
+	return mysqlTrafficTable.restore(ctx, r.db, id)
+}
+
+// Purge permanently removes a soft deleted ticket
+func (r *MySQLTrafficTicketService) Purge(ctx context.Context, id int64) (bool, error) {
+	return mysqlTrafficTable.purge(ctx, r.db, id)
 }
 
 // func (h *MySQLTrafficTicketSQLXRepository) GetPaginated_Traffic_SQL(w http.ResponseWriter, r *http.Request) {
@@ -1,7 +1,7 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"golang_daerah/internal/database"
 )
 
@@ -9,6 +9,23 @@ type PassengerPlaneService struct {
 	db *database.BaseMultiDBRepository
 }
 
+// passengerColumns are the columns clients may write
+var passengerColumns = []string{
+	"passenger_name", "passenger_id", "age", "gender",
+	"passport_number", "nationality", "flight_number", "departure_airport",
+	"arrival_airport", "departure_date", "departure_time", "arrival_time",
+	"seat_number", "ticket_class", "baggage_weight", "airline",
+	"gate", "boarding_status", "officer_name", "officer_id",
+	"officer_rank", "officer_branch_office_address", "checkin_counter", "special_request",
+}
+
+var passengerTable = resourceTable{
+	dbName:     "passenger",
+	table:      "passenger_plane",
+	columns:    passengerColumns,
+	naturalKey: []string{"passport_number", "flight_number", "departure_date"},
+}
+
 func NewPassengerPlaneService(db *database.BaseMultiDBRepository) *PassengerPlaneService {
 	return &PassengerPlaneService{db: db}
 }
@@ -37,7 +54,7 @@ func NewPassengerPlaneService(db *database.BaseMultiDBRepository) *PassengerPlan
 // 	return &PassengerPlaneSQLXRepository{db: db}
 // }
 
-func (r *PassengerPlaneService) GetPaginated(limit, offset int) ([]map[string]interface{}, error) {
+func (r *PassengerPlaneService) GetPaginated(ctx context.Context, limit, offset int, includeDeleted bool) ([]map[string]interface{}, error) {
 	// ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
 	// defer cancel()
 	// db := r.getDB(dbName)
@@ -47,13 +64,14 @@ func (r *PassengerPlaneService) GetPaginated(limit, offset int) ([]map[string]in
                departure_date, departure_time, arrival_time, seat_number, 
                ticket_class, baggage_weight, airline, gate, boarding_status,
                officer_name, officer_id, officer_rank, officer_branch_office_address, 
-               checkin_counter, special_request
+               checkin_counter, special_request, version, created_by, updated_by, deleted_at, deleted_by
         FROM passenger_plane
+        WHERE ` + liveRowsOnly(includeDeleted) + `
         ORDER BY id ASC
         LIMIT ? OFFSET ?
     `
 
-	result, err := r.db.QueryDB("passenger", query, limit, offset)
+	result, err := r.db.QueryDBContext(ctx, "passenger", query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -120,13 +138,47 @@ func (r *PassengerPlaneService) GetPaginated(limit, offset int) ([]map[string]in
 	// return json.Marshal(results)
 }
 
-func (r *PassengerPlaneService) Create(jsonData []byte) error {
+// Upsert inserts new passengers and updates existing ones matched on passport_number + flight_number + departure_date
+func (r *PassengerPlaneService) Upsert(ctx context.Context, jsonData []byte, atomic bool) (*BulkResult, error) {
+	items, err := parseBulkItems(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	return createBulk(ctx, r.db, "passenger", items, atomic, upsertWriter(passengerTable))
+}
+
+// Get returns a single passenger with its version
+func (r *PassengerPlaneService) Get(ctx context.Context, id int64, includeDeleted bool) (map[string]interface{}, error) {
+	return passengerTable.getByID(ctx, r.db, id, includeDeleted)
+}
+
+// Update changes the given fields when version still matches and returns the new version
+func (r *PassengerPlaneService) Update(ctx context.Context, id, version int64, jsonData []byte) (int64, error) {
+	return passengerTable.update(ctx, r.db, id, version, jsonData)
+}
+
+// Delete soft deletes a passenger when version still matches, recording the caller as deleted_by
+func (r *PassengerPlaneService) Delete(ctx context.Context, id, version int64) error {
+	return passengerTable.softDelete(ctx, r.db, id, version)
+}
+
+// Restore undoes a soft delete
+func (r *PassengerPlaneService) Restore(ctx context.Context, id int64) (bool, error) {
+	return passengerTable.restore(ctx, r.db, id)
+}
+
+// Purge permanently removes a soft deleted passenger
+func (r *PassengerPlaneService) Purge(ctx context.Context, id int64) (bool, error) {
+	return passengerTable.purge(ctx, r.db, id)
+}
+
+func (r *PassengerPlaneService) Create(ctx context.Context, jsonData []byte, atomic bool) (*BulkResult, error) {
 	// ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
 	// defer cancel()
 	// db := r.getDB(dbName)
-	var items []map[string]interface{}
-	if err := json.Unmarshal(jsonData, &items); err != nil {
-		return err
+	items, err := parseBulkItems(jsonData)
+	if err != nil {
+		return nil, err
 	}
 
 	query := `
@@ -135,20 +187,19 @@ func (r *PassengerPlaneService) Create(jsonData []byte) error {
             flight_number, departure_airport, arrival_airport, departure_date, 
             departure_time, arrival_time, seat_number, ticket_class, baggage_weight, 
             airline, gate, boarding_status, officer_name, officer_id, officer_rank, 
-            officer_branch_office_address, checkin_counter, special_request
+            officer_branch_office_address, checkin_counter, special_request, created_by, updated_by
         ) VALUES (
             :passenger_name, :passenger_id, :age, :gender, :passport_number, :nationality,
             :flight_number, :departure_airport, :arrival_airport, :departure_date,
             :departure_time, :arrival_time, :seat_number, :ticket_class, :baggage_weight,
             :airline, :gate, :boarding_status, :officer_name, :officer_id, :officer_rank,
-            :officer_branch_office_address, :checkin_counter, :special_request
+            :officer_branch_office_address, :checkin_counter, :special_request, :created_by, :updated_by
         )
     `
 
-	for _, item := range items {
-		if err := r.db.InsertDB("passenger", query, item); err != nil {
-			return err
-		}
+	result, err := createBulk(ctx, r.db, "passenger", items, atomic, insertWriter(query))
+	if err != nil {
+		return nil, err
 	}
 	// for _, item := range items {
 	// 	// Insert into passenger database
@@ -179,7 +230,7 @@ func (r *PassengerPlaneService) Create(jsonData []byte) error {
 	// 		userData)
 	// }
 
-	return nil
+	return result, nil
 }
 
 // func (h *PassengerPlaneSQLXRepository) GetPaginated_Passenger_SQL(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"golang_daerah/config"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// PasswordPolicyError lists every rule a rejected password broke
+type PasswordPolicyError struct {
+	Problems []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Problems, "; ")
+}
+
+var (
+	denylistOnce sync.Once
+	denylist     map[string]bool
+)
+
+// loadDenylist merges the built-in common passwords with the optional PASSWORD_DENYLIST_FILE.
+// Entries are compared case-insensitively.
+func loadDenylist(extraFile string) map[string]bool {
+	denylistOnce.Do(func() {
+		denylist = map[string]bool{}
+		addPasswords(denylist, strings.NewReader(commonPasswordsFile))
+
+		if extraFile == "" {
+			return
+		}
+		f, err := os.Open(extraFile)
+		if err != nil {
+			log.Printf("password policy: cannot read denylist %s: %v", extraFile, err)
+			return
+		}
+		defer f.Close()
+		addPasswords(denylist, f)
+	})
+	return denylist
+}
+
+func addPasswords(set map[string]bool, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			set[strings.ToLower(line)] = true
+		}
+	}
+}
+
+// ValidatePassword checks password against policy. username is rejected as part of
+// the password so accounts cannot use their own name.
+func ValidatePassword(policy config.PasswordPolicy, username, password string) error {
+	var problems []string
+
+	if len([]rune(password)) < policy.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters long", policy.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		problems = append(problems, "must contain an uppercase letter")
+	}
+	if policy.RequireLower && !hasLower {
+		problems = append(problems, "must contain a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		problems = append(problems, "must contain a digit")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		problems = append(problems, "must contain a symbol")
+	}
+
+	lower := strings.ToLower(password)
+	if loadDenylist(policy.DenylistFile)[lower] {
+		problems = append(problems, "is too common")
+	}
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		problems = append(problems, "must not contain the username")
+	}
+
+	if len(problems) > 0 {
+		return &PasswordPolicyError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang_daerah/internal/database"
+	"golang_daerah/pkg/logging"
+	"golang_daerah/pkg/notify"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidResetToken is returned for unknown, expired or already used reset tokens
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+	// ErrInvalidEmail is returned for an email address that does not parse
+	ErrInvalidEmail = errors.New("invalid email address")
+	// ErrEmailTaken is returned when another user already has the email address
+	ErrEmailTaken = errors.New("email address is already in use")
+)
+
+// normalizeEmail trims and lowercases an email address; an empty address stays empty
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// nullableString stores empty strings as NULL
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// SetUserEmail sets or clears (empty email) the email address. The affected row count is not
+// an existence check (MySQL counts only changed rows), so callers look the user up first.
+func (r *UserRepository) SetUserEmail(ctx context.Context, userID int, email string) error {
+	_, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE users SET email = :email WHERE id = :id`,
+		map[string]interface{}{"id": userID, "email": nullableString(email)})
+	if isUniqueViolation(err) {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// getUserByLogin finds a user by username or email, preferring the username match.
+// It returns nil when neither matches.
+func (r *UserRepository) getUserByLogin(ctx context.Context, login string) (*User, error) {
+	rows, err := r.QueryDBContext(ctx, "default",
+		`SELECT id, username, email, password, disabled_at FROM users WHERE username = ? OR email = ?`,
+		login, strings.ToLower(login))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	for _, row := range rows {
+		if asString(row["username"]) == login {
+			return userFromRow(row), nil
+		}
+	}
+	return userFromRow(rows[0]), nil
+}
+
+// createPasswordReset stores a reset token, replacing earlier ones so only the latest link works
+func (r *UserRepository) createPasswordReset(ctx context.Context, userID int, tokenHash string, now, expiresAt time.Time, ip string) error {
+	if _, err := r.DeleteDBContext(ctx, "default", `DELETE FROM password_reset_tokens WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return r.InsertDBContext(ctx, "default",
+		`INSERT INTO password_reset_tokens (user_id, token_hash, created_at, expires_at, requested_ip)
+		 VALUES (:user_id, :token_hash, :created_at, :expires_at, :requested_ip)`,
+		map[string]interface{}{
+			"user_id":      userID,
+			"token_hash":   tokenHash,
+			"created_at":   now,
+			"expires_at":   expiresAt,
+			"requested_ip": ip,
+		})
+}
+
+// getPasswordReset returns the user of an unused, unexpired token, or 0
+func (r *UserRepository) getPasswordReset(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	rows, err := r.QueryDBContext(ctx, "default",
+		`SELECT user_id FROM password_reset_tokens
+		 WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`,
+		tokenHash, now)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	return int(asInt64(rows[0]["user_id"])), nil
+}
+
+// resetPassword consumes the token of userID and stores the new password hash in one
+// transaction, revoking every session of the user as well. It reports false when the token was
+// used or expired in the meantime, so two requests racing with the same token cannot both
+// succeed, and a failed update leaves the token usable.
+func (r *UserRepository) resetPassword(ctx context.Context, tokenHash string, now time.Time, userID int, passwordHash string) (bool, error) {
+	reset := false
+	err := r.WithTxContext(ctx, "default", func(ctx context.Context, tx *sqlx.Tx) error {
+		consumed, err := database.NamedExec(ctx, tx,
+			`UPDATE password_reset_tokens SET used_at = :now
+			 WHERE token_hash = :token_hash AND user_id = :user_id AND used_at IS NULL AND expires_at > :now`,
+			map[string]interface{}{"token_hash": tokenHash, "user_id": userID, "now": now})
+		if err != nil || consumed == 0 {
+			return err
+		}
+		if _, err := database.NamedExec(ctx, tx,
+			`UPDATE users SET password = :password WHERE id = :id`,
+			map[string]interface{}{"id": userID, "password": passwordHash}); err != nil {
+			return err
+		}
+		if _, err := database.NamedExec(ctx, tx,
+			`UPDATE refresh_tokens SET revoked_at = :revoked_at WHERE user_id = :user_id AND revoked_at IS NULL`,
+			map[string]interface{}{"user_id": userID, "revoked_at": now}); err != nil {
+			return err
+		}
+		reset = true
+		return nil
+	})
+	return reset, err
+}
+
+// SetEmail changes the email address reset links are sent to. The current password is required,
+// otherwise a stolen access token would be enough to take the account over through a reset.
+func (s *AuthService) SetEmail(ctx context.Context, userID int, currentPassword, email string) error {
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)) != nil {
+		return ErrWrongPassword
+	}
+
+	email, err = normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	return s.Repo.SetUserEmail(ctx, user.ID, email)
+}
+
+// RequestPasswordReset sends a single-use reset token to the email address of the user named
+// by login (username or email). Unknown and disabled users and users without an email address
+// are ignored. The lookup, the token and the message are all handled in the background, so
+// neither the answer nor its timing reveals which accounts exist; failures are only logged.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, login, ip string) error {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return errors.New("username or email is required")
+	}
+
+	// The work outlives the request: keep its logger, drop its cancellation
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		if err := s.sendPasswordReset(ctx, login, ip); err != nil {
+			logging.FromContext(ctx).Error("password reset request failed", "error", err)
+		}
+	}()
+	return nil
+}
+
+// sendPasswordReset does the work of RequestPasswordReset
+func (s *AuthService) sendPasswordReset(ctx context.Context, login, ip string) error {
+	user, err := s.Repo.getUserByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Email == nil || user.DisabledAt != nil {
+		return nil
+	}
+
+	// Same format as refresh tokens: 32 random bytes, only the SHA-256 hash is stored
+	raw, tokenHash, err := newRefreshToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if err := s.Repo.createPasswordReset(ctx, user.ID, tokenHash, now, now.Add(s.ResetTTL), ip); err != nil {
+		return err
+	}
+
+	err = s.Notifier.Send(ctx, notify.Message{
+		To:      *user.Email,
+		Subject: "Password reset",
+		Body:    s.resetMessageBody(user, raw),
+	})
+	if err != nil {
+		return fmt.Errorf("notify user %d: %w", user.ID, err)
+	}
+	return nil
+}
+
+func (s *AuthService) resetMessageBody(user *User, token string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hello %s,\n\n", user.Username)
+	fmt.Fprintf(&b, "A password reset was requested for your account. It is valid for %d minutes and can be used once.\n\n",
+		int(s.ResetTTL.Minutes()))
+	if s.ResetURL != "" {
+		fmt.Fprintf(&b, "Choose a new password here:\n%s%s\n\n", s.ResetURL, token)
+	} else {
+		fmt.Fprintf(&b, "Reset token (POST /api/password/reset):\n%s\n\n", token)
+	}
+	b.WriteString("If you did not request this, ignore this message. Your password stays unchanged.\n")
+	return b.String()
+}
+
+// ConfirmPasswordReset sets a new password with a reset token. The token is only consumed
+// once the new password satisfies the policy, in the same transaction that stores it. Every
+// session is logged out and a login lockout is lifted.
+func (s *AuthService) ConfirmPasswordReset(ctx context.Context, token, newPassword string) error {
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+	tokenHash := hashToken(token)
+	now := time.Now().UTC()
+
+	userID, err := s.Repo.getPasswordReset(ctx, tokenHash, now)
+	if err != nil {
+		return err
+	}
+	if userID == 0 {
+		return ErrInvalidResetToken
+	}
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.DisabledAt != nil {
+		return ErrInvalidResetToken
+	}
+	if err := ValidatePassword(s.Policy, user.Username, newPassword); err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	reset, err := s.Repo.resetPassword(ctx, tokenHash, now, user.ID, string(hashedPassword))
+	if err != nil {
+		return err
+	}
+	if !reset {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.Throttle.Unlock(ctx, user.Username); err != nil {
+		logging.FromContext(ctx).Error("password reset: failed to lift lockout", "user_id", user.ID, "error", err)
+	}
+	return nil
+}
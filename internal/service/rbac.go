@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Roles known to the application; permissions per role live in role_permissions
+const (
+	RoleTrafficOfficer = "traffic_officer"
+	RoleAirportOfficer = "airport_officer"
+	RoleHarborMaster   = "harbor_master"
+	RoleAdmin          = "admin"
+)
+
+// GetUserRoles returns the roles assigned to a user
+func (r *UserRepository) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	rows, err := r.QueryDBContext(ctx, "default",
+		`SELECT role FROM user_roles WHERE user_id = ? ORDER BY role`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]string, 0, len(rows))
+	for _, row := range rows {
+		roles = append(roles, asString(row["role"]))
+	}
+	return roles, nil
+}
+
+// GetRolePermissions returns the union of the permissions of roles
+func (r *UserRepository) GetRolePermissions(ctx context.Context, roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return []string{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(roles)), ", ")
+	args := make([]interface{}, len(roles))
+	for i, role := range roles {
+		args[i] = role
+	}
+
+	rows, err := r.QueryDBContext(ctx, "default",
+		`SELECT DISTINCT permission FROM role_permissions WHERE role IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]string, 0, len(rows))
+	for _, row := range rows {
+		permissions = append(permissions, asString(row["permission"]))
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// loadAccess collects what goes into the access token of user
+func (r *UserRepository) loadAccess(ctx context.Context, userID int) (roles, permissions []string, err error) {
+	roles, err = r.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	permissions, err = r.GetRolePermissions(ctx, roles)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roles, permissions, nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"golang_daerah/config"
+	"golang_daerah/pkg/jwtutil"
+	"golang_daerah/pkg/logging"
+	"time"
+)
+
+// ErrInvalidRefreshToken is returned for unknown, expired, reused or revoked refresh tokens
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// DeviceInfo describes the client a session was opened from
+type DeviceInfo struct {
+	Name      string
+	UserAgent string
+	IPAddress string
+}
+
+// TokenPair is handed out on login and on every refresh
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+}
+
+// RefreshToken is one stored refresh token; only its SHA-256 hash is persisted
+type RefreshToken struct {
+	ID        int64
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	Device    DeviceInfo
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// CreateRefreshToken stores a new refresh token
+func (r *UserRepository) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	return r.InsertDBContext(ctx, "default",
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, device_name, user_agent, ip_address, created_at, expires_at)
+		 VALUES (:user_id, :family_id, :token_hash, :device_name, :user_agent, :ip_address, :created_at, :expires_at)`,
+		map[string]interface{}{
+			"user_id":     token.UserID,
+			"family_id":   token.FamilyID,
+			"token_hash":  token.TokenHash,
+			"device_name": token.Device.Name,
+			"user_agent":  token.Device.UserAgent,
+			"ip_address":  token.Device.IPAddress,
+			"created_at":  token.CreatedAt,
+			"expires_at":  token.ExpiresAt,
+		})
+}
+
+// GetRefreshTokenByHash returns nil when no token has the given hash
+func (r *UserRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	rows, err := r.QueryDBContext(ctx, "default",
+		`SELECT id, user_id, family_id, token_hash, device_name, user_agent, ip_address,
+		        created_at, expires_at, used_at, revoked_at
+		 FROM refresh_tokens WHERE token_hash = ?`,
+		tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	row := rows[0]
+	token := &RefreshToken{
+		ID:        asInt64(row["id"]),
+		UserID:    int(asInt64(row["user_id"])),
+		FamilyID:  asString(row["family_id"]),
+		TokenHash: asString(row["token_hash"]),
+		Device: DeviceInfo{
+			Name:      asString(row["device_name"]),
+			UserAgent: asString(row["user_agent"]),
+			IPAddress: asString(row["ip_address"]),
+		},
+		CreatedAt: asTime(row["created_at"]),
+		ExpiresAt: asTime(row["expires_at"]),
+	}
+	if row["used_at"] != nil {
+		usedAt := asTime(row["used_at"])
+		token.UsedAt = &usedAt
+	}
+	if row["revoked_at"] != nil {
+		revokedAt := asTime(row["revoked_at"])
+		token.RevokedAt = &revokedAt
+	}
+	return token, nil
+}
+
+// MarkRefreshTokenUsed consumes a token. It reports false when the token was already used
+// or revoked, which happens when two requests race with the same token.
+func (r *UserRepository) MarkRefreshTokenUsed(ctx context.Context, id int64) (bool, error) {
+	affected, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE refresh_tokens SET used_at = :used_at
+		 WHERE id = :id AND used_at IS NULL AND revoked_at IS NULL`,
+		map[string]interface{}{"id": id, "used_at": time.Now().UTC()})
+	return affected > 0, err
+}
+
+// RevokeRefreshFamily revokes every token of a session
+func (r *UserRepository) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	_, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE refresh_tokens SET revoked_at = :revoked_at
+		 WHERE family_id = :family_id AND revoked_at IS NULL`,
+		map[string]interface{}{"family_id": familyID, "revoked_at": time.Now().UTC()})
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every session of a user except keepFamilyID (may be empty)
+func (r *UserRepository) RevokeUserRefreshTokens(ctx context.Context, userID int, keepFamilyID string) error {
+	_, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE refresh_tokens SET revoked_at = :revoked_at
+		 WHERE user_id = :user_id AND family_id <> :keep_family_id AND revoked_at IS NULL`,
+		map[string]interface{}{"user_id": userID, "keep_family_id": keepFamilyID, "revoked_at": time.Now().UTC()})
+	return err
+}
+
+// issueTokenPair signs an access token for user and stores a new refresh token in familyID
+func (s *AuthService) issueTokenPair(ctx context.Context, user *User, familyID string, device DeviceInfo) (*TokenPair, error) {
+	// Roles are read on every issue so a refresh picks up role changes
+	roles, permissions, err := s.Repo.loadAccess(ctx, user.ID)
+	if err != nil {
+		return nil, errors.New("failed to load user roles")
+	}
+
+	accessTTL := config.GetAccessTokenTTL()
+	accessToken, err := jwtutil.IssueToken(jwtutil.Claims{
+		UserID:      user.ID,
+		Username:    user.Username,
+		SessionID:   familyID,
+		Roles:       roles,
+		Permissions: permissions,
+	}, accessTTL)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	rawToken, tokenHash, err := newRefreshToken()
+	if err != nil {
+		return nil, errors.New("failed to generate refresh token")
+	}
+
+	now := time.Now().UTC()
+	err = s.Repo.CreateRefreshToken(ctx, &RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		Device:    device,
+		CreatedAt: now,
+		ExpiresAt: now.Add(config.GetRefreshTokenTTL()),
+	})
+	if err != nil {
+		return nil, errors.New("failed to store refresh token")
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rawToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTTL.Seconds()),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Presenting a token that was
+// already used is treated as theft and revokes the whole session.
+func (s *AuthService) Refresh(ctx context.Context, rawToken string, device DeviceInfo) (*TokenPair, error) {
+	if rawToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	token, err := s.Repo.GetRefreshTokenByHash(ctx, hashToken(rawToken))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if token.UsedAt != nil {
+		s.revokeReusedFamily(ctx, token)
+		return nil, ErrInvalidRefreshToken
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	consumed, err := s.Repo.MarkRefreshTokenUsed(ctx, token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		s.revokeReusedFamily(ctx, token)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.Repo.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.DisabledAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.issueTokenPair(ctx, user, token.FamilyID, device)
+}
+
+// revokeReusedFamily ends the session of a refresh token that was presented twice. The caller
+// rejects the token either way; a failed revocation is logged because the family stays valid.
+func (s *AuthService) revokeReusedFamily(ctx context.Context, token *RefreshToken) {
+	if err := s.Repo.RevokeRefreshFamily(ctx, token.FamilyID); err != nil {
+		logging.FromContext(ctx).Error("failed to revoke reused refresh token family",
+			"user_id", token.UserID, "family_id", token.FamilyID, "error", err)
+	}
+}
+
+// Logout revokes the session the access token belongs to
+func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return errors.New("token is not bound to a session")
+	}
+	return s.Repo.RevokeRefreshFamily(ctx, sessionID)
+}
+
+// newRefreshToken returns a random opaque token and the hash that gets stored
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(b)
+	return raw, hashToken(raw), nil
+}
+
+// newFamilyID identifies the chain of refresh tokens started by one login
+func newFamilyID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang_daerah/internal/database"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the addressed record does not exist or is deleted
+	ErrNotFound = errors.New("record not found")
+	// ErrVersionConflict is returned when the record changed since the client read it
+	ErrVersionConflict = errors.New("record was modified by another request")
+)
+
+// resourceTable describes a table served through the single-record helpers below.
+// Every such table has id, version, created_by, updated_by, deleted_at and deleted_by columns.
+type resourceTable struct {
+	dbName     string
+	table      string
+	columns    []string // client writable columns
+	naturalKey []string // columns of the unique index used for upserts
+}
+
+// getByID loads one record including its version, or returns ErrNotFound
+func (t resourceTable) getByID(ctx context.Context, db *database.BaseMultiDBRepository, id int64, includeDeleted bool) (map[string]interface{}, error) {
+	query := `SELECT id, ` + strings.Join(t.columns, ", ") + `, version, created_by, updated_by, deleted_at, deleted_by
+		FROM ` + t.table + ` WHERE id = ? AND ` + liveRowsOnly(includeDeleted)
+
+	rows, err := db.QueryDBContext(ctx, t.dbName, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+	return rows[0], nil
+}
+
+// update applies the fields in jsonData when the stored version still equals version.
+// It returns the new version.
+func (t resourceTable) update(ctx context.Context, db *database.BaseMultiDBRepository, id, version int64, jsonData []byte) (int64, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(jsonData, &fields); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%w: no fields to update", ErrInvalidPayload)
+	}
+
+	allowed := make(map[string]bool, len(t.columns))
+	for _, column := range t.columns {
+		allowed[column] = true
+	}
+
+	// Build the SET clause in column order so the statement text is stable
+	var assignments []string
+	data := map[string]interface{}{"id": id, "version": version, "updated_by": actor(ctx)}
+	for _, column := range t.columns {
+		if value, ok := fields[column]; ok {
+			assignments = append(assignments, column+" = :"+column)
+			data[column] = value
+		}
+	}
+	for key := range fields {
+		if !allowed[key] {
+			return 0, fmt.Errorf("%w: unknown field %q", ErrInvalidPayload, key)
+		}
+	}
+
+	affected, err := db.UpdateDBContext(ctx, t.dbName,
+		`UPDATE `+t.table+` SET `+strings.Join(assignments, ", ")+`, updated_by = :updated_by, version = version + 1
+		 WHERE id = :id AND version = :version AND deleted_at IS NULL`,
+		data)
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, t.explainMiss(ctx, db, id)
+	}
+	return version + 1, nil
+}
+
+// softDelete marks a live row as deleted by the caller when the stored version still equals version
+func (t resourceTable) softDelete(ctx context.Context, db *database.BaseMultiDBRepository, id, version int64) error {
+	affected, err := db.UpdateDBContext(ctx, t.dbName,
+		`UPDATE `+t.table+` SET deleted_at = :deleted_at, deleted_by = :deleted_by, version = version + 1
+		 WHERE id = :id AND version = :version AND deleted_at IS NULL`,
+		map[string]interface{}{
+			"id":         id,
+			"version":    version,
+			"deleted_at": time.Now().UTC(),
+			"deleted_by": actor(ctx),
+		})
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return t.explainMiss(ctx, db, id)
+	}
+	return nil
+}
+
+// restore brings a soft deleted row back; it reports false when nothing matched
+func (t resourceTable) restore(ctx context.Context, db *database.BaseMultiDBRepository, id int64) (bool, error) {
+	affected, err := db.UpdateDBContext(ctx, t.dbName,
+		`UPDATE `+t.table+` SET deleted_at = NULL, deleted_by = NULL, updated_by = :updated_by, version = version + 1
+		 WHERE id = :id AND deleted_at IS NOT NULL`,
+		map[string]interface{}{"id": id, "updated_by": actor(ctx)})
+	return affected > 0, err
+}
+
+// purge permanently removes a row. Only rows that were soft deleted first can be purged.
+func (t resourceTable) purge(ctx context.Context, db *database.BaseMultiDBRepository, id int64) (bool, error) {
+	affected, err := db.DeleteDBContext(ctx, t.dbName,
+		`DELETE FROM `+t.table+` WHERE id = ? AND deleted_at IS NOT NULL`,
+		id)
+	return affected > 0, err
+}
+
+// explainMiss tells apart the two reasons a versioned write can match no rows
+func (t resourceTable) explainMiss(ctx context.Context, db *database.BaseMultiDBRepository, id int64) error {
+	rows, err := db.QueryDBContext(ctx, t.dbName,
+		`SELECT version FROM `+t.table+` WHERE id = ? AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+// liveRowsOnly returns the condition that hides soft deleted rows from list queries
+func liveRowsOnly(includeDeleted bool) string {
+	if includeDeleted {
+		return "1=1"
+	}
+	return "deleted_at IS NULL"
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"strconv"
+	"time"
+)
+
+// Helpers for reading values out of the maps returned by BaseMultiDBRepository.QueryDB.
+// Postgres and MySQL drivers hand back different Go types for the same column,
+// so every conversion accepts the common variants.
+
+func asInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	case float64:
+		return int64(n)
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func asString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case nil:
+		return ""
+	}
+	return ""
+}
+
+func asTime(v interface{}) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed
+			}
+		}
+	}
+	return time.Time{}
+}
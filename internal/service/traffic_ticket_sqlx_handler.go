@@ -1,7 +1,7 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"golang_daerah/internal/database"
 )
 
@@ -9,6 +9,23 @@ type TrafficService struct {
 	db *database.BaseMultiDBRepository
 }
 
+// trafficTicketColumns are the columns clients may write
+var trafficTicketColumns = []string{
+	"detected_speed", "legal_speed", "violation_location", "violation_date",
+	"violation_time", "violation_type", "license_plate_number", "vehicle_production_id",
+	"vehicle_factory", "vehicle_model", "vehicle_color", "vehicle_brand",
+	"officer_name", "officer_id", "officer_rank", "suspect_name",
+	"suspect_id", "suspect_age", "officer_age", "suspect_job",
+	"suspect_address", "suspect_birth_place", "officer_branch_office_address",
+}
+
+var postgresTrafficTable = resourceTable{
+	dbName:     "traffic",
+	table:      "traffic_tickets",
+	columns:    trafficTicketColumns,
+	naturalKey: []string{"license_plate_number", "violation_date", "violation_time"},
+}
+
 func NewPostgresTrafficTicketSQLXRepository(db *database.BaseMultiDBRepository) *TrafficService {
 	return &TrafficService{db: db}
 }
@@ -33,7 +50,7 @@ func NewPostgresTrafficTicketSQLXRepository(db *database.BaseMultiDBRepository)
 // 	return dbs
 // }
 
-func (r *TrafficService) GetPaginated(limit, offset int) ([]map[string]interface{}, error) {
+func (r *TrafficService) GetPaginated(ctx context.Context, limit, offset int, includeDeleted bool) ([]map[string]interface{}, error) {
 	// ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
 	// defer cancel()
 	// db := r.getDB(dbName)
@@ -44,13 +61,15 @@ func (r *TrafficService) GetPaginated(limit, offset int) ([]map[string]interface
                vehicle_model, vehicle_color, vehicle_brand, officer_name,
                officer_id, officer_rank, suspect_name, suspect_id, 
                suspect_age, officer_age, suspect_job, suspect_address,
-               suspect_birth_place, officer_branch_office_address
+               suspect_birth_place, officer_branch_office_address,
+               version, created_by, updated_by, deleted_at, deleted_by
         FROM traffic_tickets
+        WHERE ` + liveRowsOnly(includeDeleted) + `
         ORDER BY id ASC
         LIMIT ? OFFSET ?
     `
 
-	result, err := r.db.QueryDB("traffic", query, limit, offset)
+	result, err := r.db.QueryDBContext(ctx, "traffic", query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -112,13 +131,13 @@ func (r *TrafficService) GetPaginated(limit, offset int) ([]map[string]interface
 	// return json.Marshal(results)
 }
 
-func (r *TrafficService) Create(jsonData []byte) error {
+func (r *TrafficService) Create(ctx context.Context, jsonData []byte, atomic bool) (*BulkResult, error) {
 	// ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
 	// defer cancel()
 	// db := r.getDB(dbName)
-	var items []map[string]interface{}
-	if err := json.Unmarshal(jsonData, &items); err != nil {
-		return err
+	items, err := parseBulkItems(jsonData)
+	if err != nil {
+		return nil, err
 	}
 
 	query := `
@@ -128,21 +147,20 @@ func (r *TrafficService) Create(jsonData []byte) error {
             vehicle_production_id, vehicle_factory, vehicle_model, vehicle_color,
             vehicle_brand, officer_name, officer_id, officer_rank, suspect_name,
             suspect_id, suspect_age, officer_age, suspect_job, suspect_address,
-            suspect_birth_place, officer_branch_office_address
+            suspect_birth_place, officer_branch_office_address, created_by, updated_by
         ) VALUES (
             :detected_speed, :legal_speed, :violation_location, :violation_date,
             :violation_time, :violation_type, :license_plate_number,
             :vehicle_production_id, :vehicle_factory, :vehicle_model, :vehicle_color,
             :vehicle_brand, :officer_name, :officer_id, :officer_rank, :suspect_name,
             :suspect_id, :suspect_age, :officer_age, :suspect_job, :suspect_address,
-            :suspect_birth_place, :officer_branch_office_address
+            :suspect_birth_place, :officer_branch_office_address, :created_by, :updated_by
         )
     `
 
-	for _, item := range items {
-		if err := r.db.InsertDB("traffic", query, item); err != nil {
-			return err
-		}
+	result, err := createBulk(ctx, r.db, "traffic", items, atomic, insertWriter(query))
+	if err != nil {
+		return nil, err
 	}
 
 	// for _, item := range items {
@@ -174,7 +192,41 @@ func (r *TrafficService) Create(jsonData []byte) error {
 	// 		userData)
 	// }
 
-	return nil
+	return result, nil
+}
+
+// Upsert inserts new tickets and updates existing ones matched on license_plate_number + violation_date + violation_time
+func (r *TrafficService) Upsert(ctx context.Context, jsonData []byte, atomic bool) (*BulkResult, error) {
+	items, err := parseBulkItems(jsonData)
+	if err != nil {
+		return nil, err
+	}
+	return createBulk(ctx, r.db, "traffic", items, atomic, upsertWriter(postgresTrafficTable))
+}
+
+// Get returns a single ticket with its version
+func (r *TrafficService) Get(ctx context.Context, id int64, includeDeleted bool) (map[string]interface{}, error) {
+	return postgresTrafficTable.getByID(ctx, r.db, id, includeDeleted)
+}
+
+// Update changes the given fields when version still matches and returns the new version
+func (r *TrafficService) Update(ctx context.Context, id, version int64, jsonData []byte) (int64, error) {
+	return postgresTrafficTable.update(ctx, r.db, id, version, jsonData)
+}
+
+// Delete soft deletes a ticket when version still matches, recording the caller as deleted_by
+func (r *TrafficService) Delete(ctx context.Context, id, version int64) error {
+	return postgresTrafficTable.softDelete(ctx, r.db, id, version)
+}
+
+// Restore undoes a soft delete
+func (r *TrafficService) Restore(ctx context.Context, id int64) (bool, error) {
+	return postgresTrafficTable.restore(ctx, r.db, id)
+}
+
+// Purge permanently removes a soft deleted ticket
+func (r *TrafficService) Purge(ctx context.Context, id int64) (bool, error) {
+	return postgresTrafficTable.purge(ctx, r.db, id)
 }
 
 // func (h *PostgresTrafficTicketSQLXRepository) GetPaginated_Traffic_Postgre(w http.ResponseWriter, r *http.Request) {
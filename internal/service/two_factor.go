@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"golang_daerah/config"
+	"golang_daerah/pkg/jwtutil"
+	"golang_daerah/pkg/logging"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrTwoFactorAlreadyEnabled is returned when enrolling a user whose TOTP is already confirmed
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTwoFactorNotEnrolled is returned when confirming or disabling without an enrollment
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not enrolled")
+	// ErrInvalidTwoFactorCode is returned for a wrong, expired or replayed code
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	// ErrInvalidChallenge is returned for an unknown or expired login challenge token
+	ErrInvalidChallenge = errors.New("invalid or expired login challenge")
+)
+
+// twoFactorChallengeTTL is how long the user has to enter the code after the password step
+const twoFactorChallengeTTL = 5 * time.Minute
+
+const recoveryCodeCount = 10
+
+// LoginResult is the outcome of the password step of a login. Without 2FA it holds the token
+// pair; with 2FA it holds a challenge token that has to be completed with VerifyTwoFactor.
+type LoginResult struct {
+	*TokenPair
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
+
+// TOTPEnrollment is shown to the user once so the secret can be added to an authenticator app
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// userTOTP is one row of user_totp
+type userTOTP struct {
+	Secret      string
+	ConfirmedAt *time.Time
+	LastStep    int64
+}
+
+// getTOTP returns nil when the user never enrolled
+func (r *UserRepository) getTOTP(ctx context.Context, userID int) (*userTOTP, error) {
+	rows, err := r.QueryDBContext(ctx, "default",
+		`SELECT secret, confirmed_at, last_step FROM user_totp WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	totp := &userTOTP{
+		Secret:   asString(rows[0]["secret"]),
+		LastStep: asInt64(rows[0]["last_step"]),
+	}
+	if rows[0]["confirmed_at"] != nil {
+		confirmedAt := asTime(rows[0]["confirmed_at"])
+		totp.ConfirmedAt = &confirmedAt
+	}
+	return totp, nil
+}
+
+// saveTOTPEnrollment replaces a pending enrollment with a new secret
+func (r *UserRepository) saveTOTPEnrollment(ctx context.Context, userID int, secret string) error {
+	if err := r.deleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+	return r.InsertDBContext(ctx, "default",
+		`INSERT INTO user_totp (user_id, secret, created_at) VALUES (:user_id, :secret, :created_at)`,
+		map[string]interface{}{"user_id": userID, "secret": secret, "created_at": time.Now().UTC()})
+}
+
+// confirmTOTP activates the enrollment and stores the step of the confirming code
+func (r *UserRepository) confirmTOTP(ctx context.Context, userID int, step int64) error {
+	_, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE user_totp SET confirmed_at = :confirmed_at, last_step = :step WHERE user_id = :user_id`,
+		map[string]interface{}{"user_id": userID, "step": step, "confirmed_at": time.Now().UTC()})
+	return err
+}
+
+// advanceTOTPStep records a used step. It reports false when a concurrent request already used
+// this or a later step, which makes every code single-use.
+func (r *UserRepository) advanceTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	affected, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE user_totp SET last_step = :step WHERE user_id = :user_id AND last_step < :step`,
+		map[string]interface{}{"user_id": userID, "step": step})
+	return affected > 0, err
+}
+
+// deleteTOTP removes the enrollment and the recovery codes of a user
+func (r *UserRepository) deleteTOTP(ctx context.Context, userID int) error {
+	if _, err := r.DeleteDBContext(ctx, "default", `DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	_, err := r.DeleteDBContext(ctx, "default", `DELETE FROM user_totp WHERE user_id = ?`, userID)
+	return err
+}
+
+// replaceRecoveryCodes stores new recovery code hashes, dropping the previous set
+func (r *UserRepository) replaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	if _, err := r.DeleteDBContext(ctx, "default", `DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		err := r.InsertDBContext(ctx, "default",
+			`INSERT INTO user_recovery_codes (user_id, code_hash) VALUES (:user_id, :code_hash)`,
+			map[string]interface{}{"user_id": userID, "code_hash": codeHash})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useRecoveryCode consumes an unused recovery code; it reports false when none matched
+func (r *UserRepository) useRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	affected, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE user_recovery_codes SET used_at = :used_at
+		 WHERE user_id = :user_id AND code_hash = :code_hash AND used_at IS NULL`,
+		map[string]interface{}{"user_id": userID, "code_hash": codeHash, "used_at": time.Now().UTC()})
+	return affected > 0, err
+}
+
+// twoFactorEnabled reports whether the password step must be followed by a TOTP check
+func (s *AuthService) twoFactorEnabled(ctx context.Context, userID int) (bool, error) {
+	totp, err := s.Repo.getTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return totp != nil && totp.ConfirmedAt != nil, nil
+}
+
+// issueTwoFactorChallenge returns the short-lived token that links the password step to VerifyTwoFactor
+func (s *AuthService) issueTwoFactorChallenge(user *User) (*LoginResult, error) {
+	challenge, err := jwtutil.IssueToken(jwtutil.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Purpose:  jwtutil.PurposeTwoFactor,
+	}, twoFactorChallengeTTL)
+	if err != nil {
+		return nil, errors.New("failed to generate login challenge")
+	}
+	return &LoginResult{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+}
+
+// VerifyTwoFactor completes a login with a TOTP code or, when the device is lost, a recovery code
+func (s *AuthService) VerifyTwoFactor(ctx context.Context, challengeToken, code, recoveryCode string, device DeviceInfo) (*TokenPair, error) {
+	claims, err := jwtutil.ParsePurposeToken(challengeToken, jwtutil.PurposeTwoFactor)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	if err := s.Throttle.Check(ctx, claims.Username, device.IPAddress); err != nil {
+		return nil, err
+	}
+
+	user, err := s.Repo.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.DisabledAt != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	ok, err := s.checkSecondFactor(ctx, user.ID, code, recoveryCode)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.Throttle.RecordFailure(ctx, user.Username, device.IPAddress); err != nil {
+			logging.FromContext(ctx).Error("failed to record login failure", "error", err)
+		}
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	if err := s.Throttle.RecordSuccess(ctx, user.Username); err != nil {
+		logging.FromContext(ctx).Error("failed to reset login failures", "error", err)
+	}
+	return s.issueTokenPair(ctx, user, newFamilyID(), device)
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code
+func (s *AuthService) checkSecondFactor(ctx context.Context, userID int, code, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return s.Repo.useRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+
+	totp, err := s.Repo.getTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if totp == nil || totp.ConfirmedAt == nil {
+		return false, nil
+	}
+	step, ok := verifyTOTP(totp.Secret, code, time.Now(), totp.LastStep)
+	if !ok {
+		return false, nil
+	}
+	return s.Repo.advanceTOTPStep(ctx, userID, step)
+}
+
+// EnrollTOTP starts an enrollment. It has no effect on login until ConfirmTOTP succeeds.
+func (s *AuthService) EnrollTOTP(ctx context.Context, userID int) (*TOTPEnrollment, error) {
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	enabled, err := s.twoFactorEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, errors.New("failed to generate TOTP secret")
+	}
+	if err := s.Repo.saveTOTPEnrollment(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    totpURI(config.GetTOTPIssuer(), user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA with the first code from the authenticator app and returns the
+// recovery codes. They are only shown this once.
+func (s *AuthService) ConfirmTOTP(ctx context.Context, userID int, code string) ([]string, error) {
+	totp, err := s.Repo.getTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totp == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	step, ok := verifyTOTP(totp.Secret, code, time.Now(), totp.LastStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, errors.New("failed to generate recovery codes")
+	}
+	if err := s.Repo.replaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.confirmTOTP(ctx, userID, step); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns 2FA off after checking the password and a current code or recovery code
+func (s *AuthService) DisableTOTP(ctx context.Context, userID int, password, code, recoveryCode string) error {
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+
+	enabled, err := s.twoFactorEnabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrTwoFactorNotEnrolled
+	}
+	ok, err := s.checkSecondFactor(ctx, userID, code, recoveryCode)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return s.Repo.deleteTOTP(ctx, userID)
+}
+
+// newRecoveryCodes returns codes like "k7qm-3xva-p2rd" and their hashes
+func newRecoveryCodes(n int) ([]string, []string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789" // no look-alike characters
+	codes := make([]string, n)
+	hashes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		var sb strings.Builder
+		for j, c := range b {
+			if j > 0 && j%4 == 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(alphabet[int(c)%len(alphabet)])
+		}
+		codes[i] = sb.String()
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode makes recovery codes case and separator insensitive
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrWrongPassword is returned by ChangePassword when the current password does not match
+	ErrWrongPassword = errors.New("current password is incorrect")
+	// ErrSelfManagement is returned when an admin tries to disable or delete their own account
+	ErrSelfManagement = errors.New("admins cannot disable or delete their own account")
+)
+
+// UserProfile is a user together with the access granted through its roles
+type UserProfile struct {
+	*User
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// ListUsers returns a page of users ordered by id
+func (r *UserRepository) ListUsers(ctx context.Context, limit, offset int) ([]*User, error) {
+	rows, err := r.QueryDBContext(ctx, "default",
+		`SELECT id, username, email, password, disabled_at FROM users ORDER BY id LIMIT ? OFFSET ?`,
+		limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, userFromRow(row))
+	}
+	return users, nil
+}
+
+// SetUserDisabled sets or clears disabled_at. The affected row count is not a reliable existence
+// check (MySQL counts only changed rows), so callers look the user up first.
+func (r *UserRepository) SetUserDisabled(ctx context.Context, userID int, disabledAt *time.Time) error {
+	_, err := r.UpdateDBContext(ctx, "default",
+		`UPDATE users SET disabled_at = :disabled_at WHERE id = :id`,
+		map[string]interface{}{"id": userID, "disabled_at": disabledAt})
+	return err
+}
+
+// Profile returns a user with its roles and permissions
+func (s *AuthService) Profile(ctx context.Context, userID int) (*UserProfile, error) {
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	roles, permissions, err := s.Repo.loadAccess(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &UserProfile{User: user, Roles: roles, Permissions: permissions}, nil
+}
+
+// ChangePassword replaces the password of a user after checking the current one.
+// Every other session of the user is logged out; keepSessionID stays valid.
+func (s *AuthService) ChangePassword(ctx context.Context, userID int, keepSessionID, currentPassword, newPassword string) error {
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)) != nil {
+		return ErrWrongPassword
+	}
+
+	return s.setPassword(ctx, user, newPassword, keepSessionID)
+}
+
+// ResetPassword lets an admin set a new password; all sessions of the user are logged out
+func (s *AuthService) ResetPassword(ctx context.Context, userID int, newPassword string) error {
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.setPassword(ctx, user, newPassword, "")
+}
+
+func (s *AuthService) setPassword(ctx context.Context, user *User, newPassword, keepSessionID string) error {
+	if err := ValidatePassword(s.Policy, user.Username, newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+	if err := s.Repo.UpdateUser(ctx, user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+	return s.Repo.RevokeUserRefreshTokens(ctx, user.ID, keepSessionID)
+}
+
+// ListUsers returns a page of users for the admin API
+func (s *AuthService) ListUsers(ctx context.Context, limit, offset int) ([]*User, error) {
+	return s.Repo.ListUsers(ctx, limit, offset)
+}
+
+// DisableUser blocks logins and refreshes of a user and logs out its sessions
+func (s *AuthService) DisableUser(ctx context.Context, actorID, userID int) error {
+	if actorID == userID {
+		return ErrSelfManagement
+	}
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	now := time.Now().UTC()
+	if err := s.Repo.SetUserDisabled(ctx, user.ID, &now); err != nil {
+		return err
+	}
+	return s.Repo.RevokeUserRefreshTokens(ctx, user.ID, "")
+}
+
+// EnableUser allows a disabled user to log in again
+func (s *AuthService) EnableUser(ctx context.Context, userID int) error {
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.Repo.SetUserDisabled(ctx, user.ID, nil)
+}
+
+// DeleteUser removes a user; its sessions and roles are removed by the foreign keys
+func (s *AuthService) DeleteUser(ctx context.Context, actorID, userID int) error {
+	if actorID == userID {
+		return ErrSelfManagement
+	}
+	return s.Repo.DeleteUser(ctx, userID)
+}
@@ -8,24 +8,39 @@ import (
 	"fmt"
 	"golang_daerah/config"
 	"golang_daerah/internal/database"
-	"golang_daerah/pkg/jwtutil"
-
+	"golang_daerah/pkg/logging"
+	"golang_daerah/pkg/notify"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrUserNotFound is returned by user management operations for an unknown user id
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUserExists is returned on register when the username or email is taken
+var ErrUserExists = errors.New("username or email already exists")
+
+// ErrAccountDisabled is returned when a disabled user logs in with the right password
+var ErrAccountDisabled = errors.New("account is disabled")
+
 type User struct {
-	ID           int    `json:"id"`
-	Username     string `json:"username"`
-	PasswordHash string `json:"-"`
+	ID           int        `json:"id"`
+	Username     string     `json:"username"`
+	Email        *string    `json:"email"`
+	PasswordHash string     `json:"-"`
+	DisabledAt   *time.Time `json:"disabled_at"`
 }
 
 type Credentials struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Email      string `json:"email,omitempty"` // register only, used for password resets
+	DeviceName string `json:"device_name,omitempty"`
 }
 
 type Claims struct {
@@ -33,24 +48,47 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// mysqlDuplicateEntry is ER_DUP_ENTRY, raised by the unique indexes on users.username and users.email
+const mysqlDuplicateEntry = 1062
+
+// isUniqueViolation reports whether err was caused by a unique index on either dialect
+func isUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
 // NEW: Multi-DB User Repository
+// Every auth table lives in one store, registered as "default". Most queries are written with
+// ? placeholders and run on both dialects; the few that differ switch on dialect.
 type UserRepository struct {
 	*database.BaseMultiDBRepository
+	dialect string // "postgres" or "mysql"
 }
 
 // NEW: Constructor using multi-DB pattern
-func NewUserRepository() *UserRepository {
-	// Initialize databases for auth
-	dbConfigs := map[string]*sqlx.DB{
-		"default": config.InitGolangDBX(),
-		"auth":    config.InitAuthDBX(),
+// store is the allDBs key of the auth store (config.GetAuthStore), e.g. "golang" or "auth".
+// The shared connection is reused, nothing is opened here.
+func NewUserRepository(dbs map[string]*sqlx.DB, store string) (*UserRepository, error) {
+	db, ok := dbs[store]
+	if !ok {
+		return nil, fmt.Errorf("auth store %q is not a configured database", store)
+	}
+
+	dialect := db.DriverName()
+	if dialect != "postgres" && dialect != "mysql" {
+		return nil, fmt.Errorf("auth store %q uses unsupported driver %q", store, dialect)
 	}
 
 	return &UserRepository{
 		BaseMultiDBRepository: &database.BaseMultiDBRepository{
-			Dbs: dbConfigs,
+			Dbs: map[string]*sqlx.DB{"default": db},
 		},
-	}
+		dialect: dialect,
+	}, nil
 }
 
 // HARDCODED: Configure which databases to use for User operations
@@ -73,17 +111,29 @@ func NewUserRepository() *UserRepository {
 // }
 
 // CreateUser - Now supports multi-DB insert
-func (r *UserRepository) CreateUser(username, passwordHash string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
+// email may be empty; it is stored as NULL.
+func (r *UserRepository) CreateUser(ctx context.Context, username, email, passwordHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, config.GetQueryTimeout())
 	defer cancel()
 
 	// Insert into main database (default)
 	db := r.GetDB("default")
-	query := `INSERT INTO users (username, password) VALUES ($1, $2) ON CONFLICT (username) DO NOTHING RETURNING id;`
+	if r.dialect == "mysql" {
+		_, err := db.ExecContext(ctx, `INSERT INTO users (username, email, password) VALUES (?, ?, ?)`, username, nullableString(email), passwordHash)
+		if isUniqueViolation(err) {
+			return ErrUserExists
+		}
+		if err != nil {
+			return database.HandleQueryError(err)
+		}
+		return nil
+	}
+
+	query := `INSERT INTO users (username, email, password) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING RETURNING id;`
 	var id int
-	err := db.QueryRowContext(ctx, query, username, passwordHash).Scan(&id)
+	err := db.QueryRowContext(ctx, query, username, nullableString(email), passwordHash).Scan(&id)
 	if err == sql.ErrNoRows {
-		return errors.New("username already exists")
+		return ErrUserExists
 	}
 	if err != nil {
 		return database.HandleQueryError(err)
@@ -113,50 +163,54 @@ func (r *UserRepository) CreateUser(username, passwordHash string) error {
 	return nil
 }
 
-// GetUserByUsername - Now supports multi-DB query with fallback
-func (r *UserRepository) GetUserByUsername(username string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), config.GetQueryTimeout())
+// GetUserByUsername returns nil when no user has the given username
+func (r *UserRepository) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.GetQueryTimeout())
 	defer cancel()
 
-	// Try main database first
 	db := r.GetDB("default")
-	query := `SELECT id, username, password FROM users WHERE username = $1`
+	query := db.Rebind(`SELECT id, username, email, password, disabled_at FROM users WHERE username = ?`)
 	user := User{}
-	err := db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash)
-
+	err := db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.DisabledAt)
 	if err == sql.ErrNoRows {
-		// HARDCODED: Fallback to other databases if not found in main
-		// Try auth database
-		// 	authDB := r.getDB("auth")
-		// 	err = authDB.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash)
-		// 	if err == nil {
-		// 		fmt.Println("DEBUG: found user in auth database:", user.Username)
-		// 		return &user, nil
-		// 	}
-
-		// 	// Try mysql database
-		// 	mysqlDB := r.getDB("mysql")
-		// 	err = mysqlDB.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash)
-		// 	if err == nil {
-		// 		fmt.Println("DEBUG: found user in mysql database:", user.Username)
-		// 		return &user, nil
-		// 	}
-
-		// 	fmt.Println("DEBUG: no user found for username:", username)
-		// 	return nil, nil
-		// } else if err != nil {
-		// 	if err == context.DeadlineExceeded {
-		// 		fmt.Println("DEBUG: query timeout for username:", username)
-		// 	} else {
-		// 		fmt.Println("DEBUG: query error:", err)
-		// 	}
+		return nil, nil
+	}
+	if err != nil {
 		return nil, database.HandleQueryError(err)
 	}
-
-	fmt.Println("DEBUG: found user in default database:", user.Username)
 	return &user, nil
 }
 
+// GetUserByID returns nil when no user has the given id
+func (r *UserRepository) GetUserByID(ctx context.Context, id int) (*User, error) {
+	rows, err := r.QueryDBContext(ctx, "default", `SELECT id, username, email, password, disabled_at FROM users WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return userFromRow(rows[0]), nil
+}
+
+// userFromRow converts a QueryDB row of the users table
+func userFromRow(row map[string]interface{}) *User {
+	user := &User{
+		ID:           int(asInt64(row["id"])),
+		Username:     asString(row["username"]),
+		PasswordHash: asString(row["password"]),
+	}
+	if row["email"] != nil {
+		email := asString(row["email"])
+		user.Email = &email
+	}
+	if row["disabled_at"] != nil {
+		disabledAt := asTime(row["disabled_at"])
+		user.DisabledAt = &disabledAt
+	}
+	return user
+}
+
 // NEW: Get user from ALL databases (for admin/debugging)
 // func (r *UserRepository) GetUserFromAllDatabases(username string) (map[string]*User, error) {
 // 	results := make(map[string]*User)
@@ -182,17 +236,18 @@ func (r *UserRepository) GetUserByUsername(username string) (*User, error) {
 // }
 
 // NEW: Update user in multiple databases
-func (r *UserRepository) UpdateUser(userID int, newPassword string) error {
+// passwordHash must already be bcrypt hashed; use AuthService.ChangePassword or ResetPassword.
+func (r *UserRepository) UpdateUser(ctx context.Context, userID int, passwordHash string) error {
 	updateData := map[string]interface{}{
 		"id":       userID,
-		"password": newPassword,
+		"password": passwordHash,
 	}
 
 	// HARDCODED: Update in all databases
 	// Maintainers can easily add/remove databases
 
 	// Update in default database
-	_, err := r.UpdateDB("default",
+	_, err := r.UpdateDBContext(ctx, "default",
 		`UPDATE users SET password = :password WHERE id = :id`,
 		updateData)
 	if err != nil {
@@ -213,17 +268,20 @@ func (r *UserRepository) UpdateUser(userID int, newPassword string) error {
 }
 
 // NEW: Delete user from multiple databases
-func (r *UserRepository) DeleteUser(userID int) error {
+func (r *UserRepository) DeleteUser(ctx context.Context, userID int) error {
 	// HARDCODED: Delete from all databases
 	// Maintainers can easily add/remove databases
 
 	// Delete from default database
-	_, err := r.DeleteDB("default",
+	affected, err := r.DeleteDBContext(ctx, "default",
 		`DELETE FROM users WHERE id = ?`,
 		userID)
 	if err != nil {
 		return err
 	}
+	if affected == 0 {
+		return ErrUserNotFound
+	}
 
 	// Delete from auth database
 	// r.deleteDB("auth",
@@ -239,19 +297,38 @@ func (r *UserRepository) DeleteUser(userID int) error {
 }
 
 type AuthService struct {
-	Repo *UserRepository
+	Repo     *UserRepository
+	Throttle *LoginThrottle
+	Policy   config.PasswordPolicy
+	Notifier notify.Notifier // delivers password reset tokens
+	ResetTTL time.Duration
+	ResetURL string // prefix of the link in reset messages, may be empty
 }
 
-func NewAuthService(repo *UserRepository) *AuthService {
-	return &AuthService{Repo: repo}
+func NewAuthService(repo *UserRepository, notifier notify.Notifier) *AuthService {
+	return &AuthService{
+		Repo:     repo,
+		Throttle: NewLoginThrottle(repo, config.GetLoginThrottleSettings()),
+		Policy:   config.GetPasswordPolicy(),
+		Notifier: notifier,
+		ResetTTL: config.GetPasswordResetTTL(),
+		ResetURL: config.GetPasswordResetURL(),
+	}
 }
 
 // Register - Uses SINGLE database (original behavior)
 // Switch to CreateUserMultiDB if you want multi-database replication
-func (s *AuthService) Register(creds Credentials) error {
+func (s *AuthService) Register(ctx context.Context, creds Credentials) error {
 	if creds.Username == "" || creds.Password == "" {
 		return errors.New("username and password are required")
 	}
+	if err := ValidatePassword(s.Policy, creds.Username, creds.Password); err != nil {
+		return err
+	}
+	email, err := normalizeEmail(creds.Email)
+	if err != nil {
+		return err
+	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -259,7 +336,7 @@ func (s *AuthService) Register(creds Credentials) error {
 	}
 
 	// OPTION 1: Single database (current)
-	return s.Repo.CreateUser(creds.Username, string(hashedPassword))
+	return s.Repo.CreateUser(ctx, creds.Username, email, string(hashedPassword))
 
 	// OPTION 2: Multiple databases (uncomment to enable)
 	// return s.Repo.CreateUserMultiDB(creds.Username, string(hashedPassword))
@@ -267,30 +344,68 @@ func (s *AuthService) Register(creds Credentials) error {
 
 // Login - Uses SINGLE database (original behavior)
 // Switch to GetUserByUsernameMultiDB if you want multi-database fallback
-func (s *AuthService) Login(creds Credentials) (string, error) {
+// Failed attempts are throttled per account and per device.IPAddress.
+// Users with two-factor authentication get a challenge token instead of tokens.
+func (s *AuthService) Login(ctx context.Context, creds Credentials, device DeviceInfo) (*LoginResult, error) {
+	if err := s.Throttle.Check(ctx, creds.Username, device.IPAddress); err != nil {
+		return nil, err
+	}
+
 	// OPTION 1: Single database (current)
-	user, err := s.Repo.GetUserByUsername(creds.Username)
+	user, err := s.Repo.GetUserByUsername(ctx, creds.Username)
 
 	// OPTION 2: Multiple databases with fallback (uncomment to enable)
 	// user, err := s.Repo.GetUserByUsernameMultiDB(creds.Username)
 
+	// A failing database is not a failed login; it must neither count nor look like one
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return nil, err
 	}
-	if user == nil {
-		return "", errors.New("invalid credentials")
+	if user == nil ||
+		bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password)) != nil {
+		if err := s.Throttle.RecordFailure(ctx, creds.Username, device.IPAddress); err != nil {
+			logging.FromContext(ctx).Error("failed to record login failure", "error", err)
+		}
+		return nil, ErrInvalidCredentials
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	twoFactor, err := s.twoFactorEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor {
+		// Failures are only reset once the second factor is verified as well
+		return s.issueTwoFactorChallenge(user)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password)); err != nil {
-		return "", errors.New("invalid credentials")
+	if err := s.Throttle.RecordSuccess(ctx, user.Username); err != nil {
+		logging.FromContext(ctx).Error("failed to reset login failures", "error", err)
 	}
 
-	token, err := jwtutil.GenerateToken(user.Username, time.Hour)
+	// Every login starts a new refresh token family (session)
+	if device.Name == "" {
+		device.Name = creds.DeviceName
+	}
+	tokens, err := s.issueTokenPair(ctx, user, newFamilyID(), device)
 	if err != nil {
-		return "", errors.New("failed to generate token")
+		return nil, err
 	}
+	return &LoginResult{TokenPair: tokens}, nil
+}
 
-	return token, nil
+// UnlockUser lifts a login lockout of the user before it expires
+func (s *AuthService) UnlockUser(ctx context.Context, userID int) error {
+	user, err := s.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.Throttle.Unlock(ctx, user.Username)
 }
 
 // type UserHandler struct {
@@ -0,0 +1,9 @@
+-- MySQL auth store (AUTH_STORE=auth). Mirrors the users table of the PostgreSQL golang database;
+-- the numbering of the following files matches migrations/golang. Idempotency keys always stay
+-- in the golang database, so there is no counterpart of golang/0001_idempotency_keys.sql.
+CREATE TABLE IF NOT EXISTS users (
+    id       INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    UNIQUE KEY uq_users_username (username)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,17 @@
+-- Rotating refresh tokens, see golang/0002_refresh_tokens.sql
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id     INT          NOT NULL,
+    family_id   VARCHAR(64)  NOT NULL,
+    token_hash  CHAR(64)     NOT NULL,
+    device_name VARCHAR(255),
+    user_agent  VARCHAR(512),
+    ip_address  VARCHAR(64),
+    created_at  DATETIME     NOT NULL,
+    expires_at  DATETIME     NOT NULL,
+    used_at     DATETIME     NULL,
+    revoked_at  DATETIME     NULL,
+    UNIQUE KEY uq_refresh_tokens_hash (token_hash),
+    KEY idx_refresh_tokens_family (family_id),
+    CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,10 @@
+-- Failed login counters, see golang/0004_login_failures.sql
+CREATE TABLE IF NOT EXISTS login_failures (
+    scope           VARCHAR(16)  NOT NULL,
+    subject         VARCHAR(255) NOT NULL,
+    failures        INT          NOT NULL DEFAULT 0,
+    first_failed_at DATETIME     NOT NULL,
+    last_failed_at  DATETIME     NOT NULL,
+    locked_until    DATETIME     NULL,
+    PRIMARY KEY (scope, subject)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+-- Disabled users, see golang/0005_user_status.sql
+ALTER TABLE users
+    ADD COLUMN disabled_at DATETIME NULL;
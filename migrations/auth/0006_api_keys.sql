@@ -0,0 +1,17 @@
+-- API keys for machine clients, see golang/0006_api_keys.sql
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name         VARCHAR(255) NOT NULL,
+    owner_id     INT          NOT NULL,
+    key_prefix   VARCHAR(16)  NOT NULL,
+    key_hash     CHAR(64)     NOT NULL,
+    scopes       TEXT         NOT NULL,
+    created_by   VARCHAR(255),
+    created_at   DATETIME     NOT NULL,
+    expires_at   DATETIME     NULL,
+    last_used_at DATETIME     NULL,
+    revoked_at   DATETIME     NULL,
+    UNIQUE KEY uq_api_keys_hash (key_hash),
+    KEY idx_api_keys_owner (owner_id),
+    CONSTRAINT fk_api_keys_owner FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,18 @@
+-- TOTP two-factor authentication, see golang/0007_two_factor.sql
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id      INT         NOT NULL PRIMARY KEY,
+    secret       VARCHAR(64) NOT NULL,
+    created_at   DATETIME    NOT NULL,
+    confirmed_at DATETIME    NULL,
+    last_step    BIGINT      NOT NULL DEFAULT 0,
+    CONSTRAINT fk_user_totp_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id        BIGINT   NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id   INT      NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at   DATETIME NULL,
+    KEY idx_user_recovery_codes_user (user_id),
+    CONSTRAINT fk_user_recovery_codes_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,17 @@
+-- Password reset, see golang/0008_password_reset.sql
+ALTER TABLE users
+    ADD COLUMN email VARCHAR(255) NULL,
+    ADD UNIQUE KEY uq_users_email (email);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id           BIGINT      NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id      INT         NOT NULL,
+    token_hash   CHAR(64)    NOT NULL,
+    requested_ip VARCHAR(64),
+    created_at   DATETIME    NOT NULL,
+    expires_at   DATETIME    NOT NULL,
+    used_at      DATETIME    NULL,
+    UNIQUE KEY uq_password_reset_tokens_hash (token_hash),
+    KEY idx_password_reset_tokens_user (user_id),
+    CONSTRAINT fk_password_reset_tokens_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,13 @@
+-- Stored responses for Idempotency-Key replays on create endpoints.
+-- status_code stays 0 while the original request is still being processed.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    username        VARCHAR(255) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash    CHAR(64)     NOT NULL,
+    status_code     INT          NOT NULL DEFAULT 0,
+    response_body   TEXT,
+    created_at      TIMESTAMP    NOT NULL,
+    PRIMARY KEY (username, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys (created_at);
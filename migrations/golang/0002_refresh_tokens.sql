@@ -0,0 +1,18 @@
+-- Rotating refresh tokens. Every login starts a family; each refresh marks the presented token
+-- as used and issues a new one in the same family. Presenting a used or revoked token
+-- revokes the whole family.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          BIGSERIAL    PRIMARY KEY,
+    user_id     INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    family_id   VARCHAR(64)  NOT NULL,
+    token_hash  CHAR(64)     NOT NULL UNIQUE,
+    device_name VARCHAR(255),
+    user_agent  VARCHAR(512),
+    ip_address  VARCHAR(64),
+    created_at  TIMESTAMP    NOT NULL,
+    expires_at  TIMESTAMP    NOT NULL,
+    used_at     TIMESTAMP    NULL,
+    revoked_at  TIMESTAMP    NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family_id);
@@ -0,0 +1,32 @@
+-- Role based access control. Roles are assigned per user; permissions per role.
+-- Both are embedded in the access token at login and refresh.
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role    VARCHAR(64) NOT NULL,
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role       VARCHAR(64) NOT NULL,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('traffic_officer', 'tickets:read'),
+    ('traffic_officer', 'tickets:create'),
+    ('traffic_officer', 'tickets:update'),
+    ('traffic_officer', 'tickets:delete'),
+    ('airport_officer', 'passengers:read'),
+    ('airport_officer', 'passengers:create'),
+    ('airport_officer', 'passengers:update'),
+    ('airport_officer', 'passengers:delete'),
+    ('harbor_master',   'ports:read'),
+    ('harbor_master',   'ports:create'),
+    ('harbor_master',   'ports:update'),
+    ('harbor_master',   'ports:delete'),
+    ('admin',           '*')
+ON CONFLICT DO NOTHING;
+
+-- Grant the first administrator by hand, e.g.:
+-- INSERT INTO user_roles (user_id, role) SELECT id, 'admin' FROM users WHERE username = 'alice';
@@ -0,0 +1,11 @@
+-- Failed login counters used for progressive delays and temporary lockouts.
+-- scope is 'account' (subject = username) or 'ip' (subject = client IP).
+CREATE TABLE IF NOT EXISTS login_failures (
+    scope           VARCHAR(16)  NOT NULL,
+    subject         VARCHAR(255) NOT NULL,
+    failures        INT          NOT NULL DEFAULT 0,
+    first_failed_at TIMESTAMP    NOT NULL,
+    last_failed_at  TIMESTAMP    NOT NULL,
+    locked_until    TIMESTAMP    NULL,
+    PRIMARY KEY (scope, subject)
+);
@@ -0,0 +1,3 @@
+-- Disabled users cannot log in or refresh; admins toggle this through /api/admin/users/{id}/disable and /enable
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP NULL;
@@ -0,0 +1,18 @@
+-- API keys for machine clients (speed cameras, check-in systems). Only the SHA-256 hash of a key
+-- is stored; key_prefix is kept so admins can tell keys apart. scopes is a comma separated list
+-- of permissions, see pkg/jwtutil/permissions.go.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           BIGSERIAL    PRIMARY KEY,
+    name         VARCHAR(255) NOT NULL,
+    owner_id     INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    key_prefix   VARCHAR(16)  NOT NULL,
+    key_hash     CHAR(64)     NOT NULL UNIQUE,
+    scopes       TEXT         NOT NULL,
+    created_by   VARCHAR(255),
+    created_at   TIMESTAMP    NOT NULL,
+    expires_at   TIMESTAMP    NULL,
+    last_used_at TIMESTAMP    NULL,
+    revoked_at   TIMESTAMP    NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_owner ON api_keys (owner_id);
@@ -0,0 +1,19 @@
+-- TOTP two-factor authentication (RFC 6238). A row without confirmed_at is a pending enrollment.
+-- last_step is the last accepted 30 second time step, so a code cannot be replayed.
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id      INT          PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret       VARCHAR(64)  NOT NULL,
+    created_at   TIMESTAMP    NOT NULL,
+    confirmed_at TIMESTAMP    NULL,
+    last_step    BIGINT       NOT NULL DEFAULT 0
+);
+
+-- One-time recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id        BIGSERIAL PRIMARY KEY,
+    user_id   INT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash CHAR(64)  NOT NULL,
+    used_at   TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user ON user_recovery_codes (user_id);
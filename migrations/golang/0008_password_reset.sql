@@ -0,0 +1,18 @@
+-- Password reset. Users can set an email address (POST /api/me/email) that reset tokens are sent to.
+-- Only the SHA-256 hash of a token is stored; a token is single-use and replaced by the next request.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email VARCHAR(255) NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id           BIGSERIAL   PRIMARY KEY,
+    user_id      INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash   CHAR(64)    NOT NULL UNIQUE,
+    requested_ip VARCHAR(64),
+    created_at   TIMESTAMP   NOT NULL,
+    expires_at   TIMESTAMP   NOT NULL,
+    used_at      TIMESTAMP   NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens (user_id);
@@ -0,0 +1,4 @@
+-- Soft delete for traffic tickets (MySQL traffic_ticket database)
+ALTER TABLE traffic_tickets
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD COLUMN deleted_by VARCHAR(255) NULL;
@@ -0,0 +1,3 @@
+-- Row version for optimistic concurrency (ETag / If-Match)
+ALTER TABLE traffic_tickets
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- Natural key used by ?mode=upsert on /api/traffic_tickets/mysql_create.
+-- Remove existing duplicates before applying.
+ALTER TABLE traffic_tickets
+    ADD UNIQUE KEY ux_traffic_tickets_natural_key (license_plate_number, violation_date, violation_time);
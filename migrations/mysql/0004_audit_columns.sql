@@ -0,0 +1,4 @@
+-- Who created and last changed each traffic ticket (MySQL traffic_ticket database)
+ALTER TABLE traffic_tickets
+    ADD COLUMN created_by VARCHAR(255) NULL,
+    ADD COLUMN updated_by VARCHAR(255) NULL;
@@ -0,0 +1,4 @@
+-- Soft delete for passengers (MySQL passenger database)
+ALTER TABLE passenger_plane
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD COLUMN deleted_by VARCHAR(255) NULL;
@@ -0,0 +1,4 @@
+-- Natural key used by ?mode=upsert on /api/passengers/create.
+-- Remove existing duplicates before applying.
+ALTER TABLE passenger_plane
+    ADD UNIQUE KEY ux_passenger_plane_natural_key (passport_number, flight_number, departure_date);
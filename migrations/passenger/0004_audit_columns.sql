@@ -0,0 +1,4 @@
+-- Who created and last changed each passenger (MySQL passenger database)
+ALTER TABLE passenger_plane
+    ADD COLUMN created_by VARCHAR(255) NULL,
+    ADD COLUMN updated_by VARCHAR(255) NULL;
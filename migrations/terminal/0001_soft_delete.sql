@@ -0,0 +1,4 @@
+-- Soft delete for ports (MySQL terminal database)
+ALTER TABLE Laut
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD COLUMN deleted_by VARCHAR(255) NULL;
@@ -0,0 +1,4 @@
+-- Natural key used by ?mode=upsert on /api/terminals/create.
+-- Remove existing duplicates before applying.
+ALTER TABLE Laut
+    ADD UNIQUE KEY ux_laut_port_code (port_code);
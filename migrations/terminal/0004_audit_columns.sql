@@ -0,0 +1,4 @@
+-- Who created and last changed each port (MySQL terminal database)
+ALTER TABLE Laut
+    ADD COLUMN created_by VARCHAR(255) NULL,
+    ADD COLUMN updated_by VARCHAR(255) NULL;
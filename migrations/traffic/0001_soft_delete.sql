@@ -0,0 +1,4 @@
+-- Soft delete for traffic tickets (PostgreSQL traffic_ticket database)
+ALTER TABLE traffic_tickets
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL,
+    ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(255) NULL;
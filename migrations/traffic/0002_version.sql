@@ -0,0 +1,3 @@
+-- Row version for optimistic concurrency (ETag / If-Match)
+ALTER TABLE traffic_tickets
+    ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
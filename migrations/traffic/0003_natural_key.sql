@@ -0,0 +1,4 @@
+-- Natural key used by ?mode=upsert on /api/traffic_tickets/postgres_create.
+-- Remove existing duplicates before applying.
+CREATE UNIQUE INDEX IF NOT EXISTS ux_traffic_tickets_natural_key
+    ON traffic_tickets (license_plate_number, violation_date, violation_time);
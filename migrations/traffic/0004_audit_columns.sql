@@ -0,0 +1,4 @@
+-- Who created and last changed each traffic ticket (PostgreSQL traffic_ticket database)
+ALTER TABLE traffic_tickets
+    ADD COLUMN IF NOT EXISTS created_by VARCHAR(255) NULL,
+    ADD COLUMN IF NOT EXISTS updated_by VARCHAR(255) NULL;
@@ -0,0 +1,40 @@
+package jwtutil
+
+// Request Flow Link:
+// main.go installs the API key service with SetAPIKeyAuthenticator; AuthMiddleware then accepts an
+// X-API-Key header as an alternative to a bearer token and stores the key's Principal in the context.
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// APIKeyHeader carries the key of machine clients
+const APIKeyHeader = "X-API-Key"
+
+// ErrInvalidAPIKey is returned for unknown, expired or revoked keys
+var ErrInvalidAPIKey = errors.New("invalid or expired API key")
+
+// APIKeyAuthenticator resolves a raw API key to the principal it acts as
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, rawKey string) (*Principal, error)
+}
+
+type apiKeyAuthenticatorHolder struct {
+	APIKeyAuthenticator
+}
+
+var activeAPIKeyAuthenticator atomic.Pointer[apiKeyAuthenticatorHolder]
+
+// SetAPIKeyAuthenticator enables the X-API-Key path of AuthMiddleware
+func SetAPIKeyAuthenticator(a APIKeyAuthenticator) {
+	activeAPIKeyAuthenticator.Store(&apiKeyAuthenticatorHolder{a})
+}
+
+func currentAPIKeyAuthenticator() APIKeyAuthenticator {
+	if holder := activeAPIKeyAuthenticator.Load(); holder != nil {
+		return holder.APIKeyAuthenticator
+	}
+	return nil
+}
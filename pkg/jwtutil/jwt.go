@@ -5,6 +5,8 @@ package jwtutil
 // AuthMiddleware ultimately use the helpers in this file for signing and verification.
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"strings"
 	"time"
@@ -12,35 +14,82 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your_very_secret_key")
-
 type Claims struct {
-	Username string `json:"username"`
+	UserID      int      `json:"uid,omitempty"`
+	Username    string   `json:"username"`
+	SessionID   string   `json:"sid,omitempty"` // refresh token family the access token belongs to
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	Purpose     string   `json:"purpose,omitempty"` // set on single-purpose tokens such as 2FA challenges
 	jwt.RegisteredClaims
 }
 
+// PurposeTwoFactor marks the challenge token handed out between password and TOTP check
+const PurposeTwoFactor = "2fa_challenge"
+
 func GenerateToken(username string, duration time.Duration) (string, error) {
-	expiration := time.Now().Add(duration)
-	claims := &Claims{
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiration),
-		},
+	return IssueToken(Claims{Username: username}, duration)
+}
+
+// IssueToken signs claims with the active key, filling in issued-at, expiry and a unique token id
+func IssueToken(claims Claims, duration time.Duration) (string, error) {
+	now := time.Now()
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(duration))
+	if claims.ID == "" {
+		claims.ID = newTokenID()
+	}
+	ks, err := currentKeySet()
+	if err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return ks.sign(&claims)
 }
 
 func VerifyToken(authHeader string) (string, error) {
-	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+	claims, err := ParseToken(authHeader)
+	if err != nil {
+		return "", err
+	}
+	return claims.Username, nil
+}
+
+// ParseToken verifies a bearer token (with or without the "Bearer " prefix) and returns its claims.
+// Single-purpose tokens are rejected so a 2FA challenge cannot be used as an access token.
+func ParseToken(authHeader string) (*Claims, error) {
+	claims, err := parseClaims(strings.TrimPrefix(authHeader, "Bearer "))
+	if err != nil || claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// ParsePurposeToken verifies a single-purpose token issued with IssueToken and Claims.Purpose
+func ParsePurposeToken(tokenStr, purpose string) (*Claims, error) {
+	claims, err := parseClaims(tokenStr)
+	if err != nil || claims.Purpose != purpose {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+func parseClaims(tokenStr string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	ks, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, ks.keyFunc, jwt.WithValidMethods(ks.validMethods()))
 	if err != nil || !token.Valid {
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
-	return claims.Username, nil
+	return claims, nil
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
@@ -0,0 +1,218 @@
+package jwtutil
+
+// Request Flow Link:
+// main.go loads the KeySet from configuration at startup; GenerateToken signs with its active key
+// and VerifyToken picks the verification key named by the token's kid header.
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang_daerah/config"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is one signing or verification key identified by its kid
+type Key struct {
+	ID        string
+	Algorithm string
+	method    jwt.SigningMethod
+	sign      interface{} // nil for verification-only keys
+	verify    interface{}
+}
+
+// CanSign reports whether the key holds private material
+func (k *Key) CanSign() bool {
+	return k.sign != nil
+}
+
+// KeySet holds the active signing key and every key accepted for verification
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+var activeKeySet atomic.Pointer[KeySet]
+
+// SetKeySet installs the key set used by GenerateToken and VerifyToken
+func SetKeySet(ks *KeySet) {
+	activeKeySet.Store(ks)
+}
+
+// errNoKeySet is returned when tokens are issued or verified before SetKeySet was called
+var errNoKeySet = errors.New("no JWT key set installed")
+
+func currentKeySet() (*KeySet, error) {
+	ks := activeKeySet.Load()
+	if ks == nil {
+		return nil, errNoKeySet
+	}
+	return ks, nil
+}
+
+// NewKeySet builds a key set that signs with the key named signingKID
+func NewKeySet(signingKID string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate JWT key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	signing, ok := ks.keys[signingKID]
+	if !ok {
+		return nil, fmt.Errorf("JWT signing key %q is not configured", signingKID)
+	}
+	if !signing.CanSign() {
+		return nil, fmt.Errorf("JWT signing key %q has no private key", signingKID)
+	}
+	ks.signing = signing
+	return ks, nil
+}
+
+// LoadKeySet reads the configured key files. It fails when neither JWT_KEYS nor JWT_SECRET is
+// set, since a well-known default secret would let anyone forge tokens.
+func LoadKeySet(settings config.JWTSettings) (*KeySet, error) {
+	if len(settings.Keys) == 0 {
+		secret := []byte(settings.Secret)
+		if len(secret) == 0 {
+			return nil, errors.New("no JWT key configured, set JWT_KEYS or JWT_SECRET")
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("JWT_SECRET must be at least 32 bytes")
+		}
+		return NewKeySet("default", NewHMACKey("default", secret))
+	}
+
+	keys := make([]*Key, 0, len(settings.Keys))
+	for _, spec := range settings.Keys {
+		key, err := loadKey(spec)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(settings.SigningKeyID, keys...)
+}
+
+// NewHMACKey creates an HS256 key; the secret both signs and verifies
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Algorithm: "HS256", method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+}
+
+func loadKey(spec config.JWTKeySpec) (*Key, error) {
+	data, err := os.ReadFile(spec.Path)
+	if err != nil {
+		return nil, fmt.Errorf("JWT key %q: %w", spec.ID, err)
+	}
+
+	switch spec.Algorithm {
+	case "HS256":
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("JWT key %q: HS256 secrets must be at least 32 bytes", spec.ID)
+		}
+		return NewHMACKey(spec.ID, secret), nil
+	case "RS256", "EdDSA":
+		return parsePEMKey(spec, data)
+	default:
+		return nil, fmt.Errorf("JWT key %q: unsupported algorithm %q", spec.ID, spec.Algorithm)
+	}
+}
+
+// parsePEMKey accepts a private key (PKCS#1 or PKCS#8) or a public key (PKIX)
+func parsePEMKey(spec config.JWTKeySpec, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("JWT key %q: no PEM block found", spec.ID)
+	}
+
+	key := &Key{ID: spec.ID, Algorithm: spec.Algorithm}
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("JWT key %q: unsupported PEM block %q", spec.ID, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("JWT key %q: %w", spec.ID, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.sign, key.verify = k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.verify = k
+	case ed25519.PrivateKey:
+		key.sign, key.verify = k, k.Public().(ed25519.PublicKey)
+	case ed25519.PublicKey:
+		key.verify = k
+	default:
+		return nil, fmt.Errorf("JWT key %q: unsupported key type %T", spec.ID, parsed)
+	}
+
+	switch spec.Algorithm {
+	case "RS256":
+		if _, ok := key.verify.(*rsa.PublicKey); !ok {
+			return nil, fmt.Errorf("JWT key %q: RS256 needs an RSA key", spec.ID)
+		}
+		key.method = jwt.SigningMethodRS256
+	case "EdDSA":
+		if _, ok := key.verify.(ed25519.PublicKey); !ok {
+			return nil, fmt.Errorf("JWT key %q: EdDSA needs an Ed25519 key", spec.ID)
+		}
+		key.method = jwt.SigningMethodEdDSA
+	}
+	return key, nil
+}
+
+// sign creates a signed token with the active key and its kid header
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.sign)
+}
+
+// keyFunc resolves the verification key from the kid header and refuses any
+// algorithm other than the one the key was configured with
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key := ks.signing
+	if kid != "" {
+		var ok bool
+		if key, ok = ks.keys[kid]; !ok {
+			return nil, errors.New("unknown key id")
+		}
+	}
+	if t.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing algorithm")
+	}
+	return key.verify, nil
+}
+
+// validMethods lists the algorithms of all configured keys
+func (ks *KeySet) validMethods() []string {
+	seen := map[string]bool{}
+	var methods []string
+	for _, key := range ks.keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			methods = append(methods, key.Algorithm)
+		}
+	}
+	return methods
+}
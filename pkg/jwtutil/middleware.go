@@ -5,24 +5,57 @@ package jwtutil
 // and the concrete handler to ensure only requests with valid JWT tokens continue down the chain.
 
 import (
+	"errors"
+	"golang_daerah/pkg/logging"
 	"net/http"
 )
 
-// AuthMiddleware checks the Authorization header using VerifyToken
+// AuthMiddleware checks the Authorization header, or the X-API-Key header of machine clients,
+// and stores the caller as a Principal in the request context
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if rawKey := r.Header.Get(APIKeyHeader); rawKey != "" {
+			authenticateAPIKey(w, r, rawKey, next)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
 			return
 		}
 
-		_, err := VerifyToken(authHeader)
+		claims, err := ParseToken(authHeader)
 		if err != nil {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		principal := NewPrincipal(claims)
+		ctx := logging.SetUser(WithPrincipal(r.Context(), principal), principal.Username)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// authenticateAPIKey is the X-API-Key path of AuthMiddleware
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, rawKey string, next http.HandlerFunc) {
+	authenticator := currentAPIKeyAuthenticator()
+	if authenticator == nil {
+		http.Error(w, "API keys are not enabled", http.StatusUnauthorized)
+		return
 	}
+
+	principal, err := authenticator.AuthenticateAPIKey(r.Context(), rawKey)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidAPIKey) {
+			logging.FromContext(r.Context()).Error("api key authentication failed", "error", err)
+			http.Error(w, "Failed to check API key", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "Invalid or expired API key", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := logging.SetUser(WithPrincipal(r.Context(), principal), principal.Username)
+	next.ServeHTTP(w, r.WithContext(ctx))
 }
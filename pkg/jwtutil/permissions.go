@@ -0,0 +1,105 @@
+package jwtutil
+
+// Request Flow Link:
+// main.go wraps each protected route with RequirePermission inside AuthMiddleware, so the
+// permissions embedded in the access token at login decide which endpoints a user may reach.
+
+import (
+	"golang_daerah/pkg/response"
+	"net/http"
+)
+
+// Permissions checked by the routes in main.go. Roles map onto these in the role_permissions table.
+const (
+	PermTicketsRead   = "tickets:read"
+	PermTicketsCreate = "tickets:create"
+	PermTicketsUpdate = "tickets:update"
+	PermTicketsDelete = "tickets:delete"
+
+	PermPassengersRead   = "passengers:read"
+	PermPassengersCreate = "passengers:create"
+	PermPassengersUpdate = "passengers:update"
+	PermPassengersDelete = "passengers:delete"
+
+	PermPortsRead   = "ports:read"
+	PermPortsCreate = "ports:create"
+	PermPortsUpdate = "ports:update"
+	PermPortsDelete = "ports:delete"
+
+	PermRecordsReadDeleted = "records:read_deleted"
+	PermRecordsPurge       = "records:purge"
+
+	PermUsersManage   = "users:manage"
+	PermAPIKeysManage = "api_keys:manage"
+
+	// PermAll is granted to admins and satisfies every check
+	PermAll = "*"
+)
+
+// KnownPermissions lists every permission above except PermAll
+var KnownPermissions = []string{
+	PermTicketsRead, PermTicketsCreate, PermTicketsUpdate, PermTicketsDelete,
+	PermPassengersRead, PermPassengersCreate, PermPassengersUpdate, PermPassengersDelete,
+	PermPortsRead, PermPortsCreate, PermPortsUpdate, PermPortsDelete,
+	PermRecordsReadDeleted, PermRecordsPurge,
+	PermUsersManage, PermAPIKeysManage,
+}
+
+// IsKnownPermission reports whether permission is one of KnownPermissions
+func IsKnownPermission(permission string) bool {
+	for _, p := range KnownPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the claims grant permission
+func (c *Claims) HasPermission(permission string) bool {
+	return hasPermission(c.Permissions, permission)
+}
+
+func hasPermission(granted []string, permission string) bool {
+	for _, p := range granted {
+		if p == permission || p == PermAll {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission lets the request through only when the principal set by AuthMiddleware
+// was granted permission
+func RequirePermission(permission string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromRequest(r)
+			if !ok {
+				response.WriteUnauthorized(w, "Invalid or expired token")
+				return
+			}
+			if !principal.HasPermission(permission) {
+				response.WriteForbidden(w, "Permission "+permission+" is required for this action")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// RequireMethodPermission is RequirePermission for routes that serve several methods,
+// e.g. GET/PUT/DELETE on a single record. Methods missing from the map are passed through;
+// the route's AllowMethods answers them with 405.
+func RequireMethodPermission(permissions map[string]string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			permission, ok := permissions[r.Method]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			RequirePermission(permission)(next).ServeHTTP(w, r)
+		}
+	}
+}
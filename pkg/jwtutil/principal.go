@@ -0,0 +1,75 @@
+package jwtutil
+
+// Request Flow Link:
+// AuthMiddleware stores the Principal of a verified token in the request context; permission
+// checks, handlers and services read it back with PrincipalFromContext instead of re-parsing
+// the Authorization header.
+
+import (
+	"context"
+	"net/http"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID      int
+	Username    string
+	Roles       []string
+	Permissions []string
+	TokenID     string // jti of the access token
+	SessionID   string // refresh token family the access token belongs to
+	APIKeyID    int64  // set when the request authenticated with X-API-Key
+}
+
+type principalKey struct{}
+
+// NewPrincipal builds the principal described by verified claims
+func NewPrincipal(claims *Claims) *Principal {
+	return &Principal{
+		UserID:      claims.UserID,
+		Username:    claims.Username,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		TokenID:     claims.ID,
+		SessionID:   claims.SessionID,
+	}
+}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by AuthMiddleware
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// PrincipalFromRequest is PrincipalFromContext for the request context
+func PrincipalFromRequest(r *http.Request) (*Principal, bool) {
+	return PrincipalFromContext(r.Context())
+}
+
+// UsernameFromContext returns the username of the principal, or "" when there is none
+func UsernameFromContext(ctx context.Context) string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return p.Username
+	}
+	return ""
+}
+
+// HasPermission reports whether the principal was granted permission
+func (p *Principal) HasPermission(permission string) bool {
+	return hasPermission(p.Permissions, permission)
+}
+
+// HasRole reports whether the principal holds role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package logging
+
+// Request Flow Link:
+// main.go installs the logger built by New as the slog default. RequestLogMiddleware stores a
+// child logger carrying the request ID in every request context; AuthMiddleware adds the user,
+// and handlers, services and BaseMultiDBRepository log through FromContext so their lines can
+// be matched with the access log line of the same request.
+
+import (
+	"context"
+	"fmt"
+	"golang_daerah/config"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// New creates the application logger writing to stderr in the configured level and format
+func New(settings config.LogSettings) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(settings.Level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", settings.Level)
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(settings.Format) {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected json or text", settings.Format)
+	}
+}
+
+type loggerKey struct{}
+type requestKey struct{}
+
+// WithLogger returns a copy of ctx carrying l
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger of the request, or the default logger outside of requests
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// RequestInfo is what the access log learns about a request while it is handled. Middleware
+// further down the chain works on copies of the request, so it is shared through a pointer.
+type RequestInfo struct {
+	ID string
+
+	mu   sync.Mutex
+	user string
+}
+
+// User returns the user recorded by SetUser
+func (i *RequestInfo) User() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.user
+}
+
+// WithRequest returns a copy of ctx carrying the request info and a logger tagged with its ID
+func WithRequest(ctx context.Context, info *RequestInfo) context.Context {
+	ctx = context.WithValue(ctx, requestKey{}, info)
+	return WithLogger(ctx, FromContext(ctx).With("request_id", info.ID))
+}
+
+// RequestFromContext returns the request info stored by RequestLogMiddleware
+func RequestFromContext(ctx context.Context) (*RequestInfo, bool) {
+	info, ok := ctx.Value(requestKey{}).(*RequestInfo)
+	return info, ok && info != nil
+}
+
+// RequestIDFromContext returns the request ID, or "" outside of requests
+func RequestIDFromContext(ctx context.Context) string {
+	if info, ok := RequestFromContext(ctx); ok {
+		return info.ID
+	}
+	return ""
+}
+
+// SetUser records the authenticated user for the access log and returns a copy of ctx whose
+// logger carries it
+func SetUser(ctx context.Context, user string) context.Context {
+	if info, ok := RequestFromContext(ctx); ok {
+		info.mu.Lock()
+		info.user = user
+		info.mu.Unlock()
+	}
+	return WithLogger(ctx, FromContext(ctx).With("user", user))
+}
@@ -0,0 +1,189 @@
+package middleware
+
+// Request Flow Link:
+// main.go wraps the whole router with ClientIPResolver.Middleware, so the client IP is resolved
+// once per request and stored in the context. Rate limiting, login throttling and session
+// metadata read it back with ClientIPFromRequest.
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// ClientIPResolver finds the client address of a request. Forwarding headers are only believed
+// when they were added by a trusted proxy, otherwise any client could pick its own IP.
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+	// chain reads the one forwarding header the proxies write. The other header is ignored:
+	// proxies pass it through unchanged, so its content comes from the client.
+	chain func(http.Header) []string
+}
+
+// NewClientIPResolver creates a resolver trusting the given proxy CIDRs or single IPs. header
+// names the forwarding header those proxies set: "x-forwarded-for" or "forwarded" (RFC 7239).
+func NewClientIPResolver(trustedProxies []string, header string) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{}
+	switch strings.ToLower(strings.TrimSpace(header)) {
+	case "x-forwarded-for":
+		resolver.chain = func(h http.Header) []string { return xForwardedFor(h.Values("X-Forwarded-For")) }
+	case "forwarded":
+		resolver.chain = func(h http.Header) []string { return forwardedFor(h.Values("Forwarded")) }
+	default:
+		return nil, fmt.Errorf("invalid trusted proxy header %q: want x-forwarded-for or forwarded", header)
+	}
+	for _, entry := range trustedProxies {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			resolver.trusted = append(resolver.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		resolver.trusted = append(resolver.trusted, prefix.Masked())
+	}
+	return resolver, nil
+}
+
+func (c *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client IP of r. Starting at the direct peer, the forwarding chain is
+// walked right to left while the hops are trusted proxies; the first untrusted hop is the client.
+// Only the configured forwarding header is read.
+func (c *ClientIPResolver) Resolve(r *http.Request) string {
+	peer, ok := parseIP(r.RemoteAddr)
+	if !ok {
+		return remoteHost(r.RemoteAddr)
+	}
+	if !c.isTrusted(peer) {
+		return peer.String()
+	}
+
+	chain := c.chain(r.Header)
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop, ok := parseIP(chain[i])
+		if !ok {
+			// Garbage (or "unknown") written by a trusted proxy: stop at the last good hop
+			break
+		}
+		client = hop
+		if !c.isTrusted(hop) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// Middleware stores the resolved client IP in the request context
+func (c *ClientIPResolver) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPKey{}, c.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// ClientIPFromContext returns the IP stored by ClientIPResolver.Middleware, or ""
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// ClientIPFromRequest returns the resolved client IP, falling back to the peer address when
+// the resolver middleware is not installed
+func ClientIPFromRequest(r *http.Request) string {
+	if ip := ClientIPFromContext(r.Context()); ip != "" {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// xForwardedFor splits X-Forwarded-For headers into hops, leftmost (original client) first
+func xForwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(hop))
+		}
+	}
+	return chain
+}
+
+// forwardedFor extracts the for= parameters of RFC 7239 Forwarded headers, leftmost first.
+// An element without for= yields "" so that the chain stays aligned with the proxies that wrote it.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			hop := ""
+			for _, pair := range splitQuoted(element, ';') {
+				name, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(strings.TrimSpace(name), "for") {
+					hop = strings.Trim(strings.TrimSpace(val), `"`)
+				}
+			}
+			chain = append(chain, hop)
+		}
+	}
+	return chain
+}
+
+// splitQuoted splits s at sep outside of double quoted strings
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseIP accepts "ip", "ip:port", "[ipv6]" and "[ipv6]:port"
+func parseIP(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// remoteHost strips the port from a RemoteAddr that did not parse as an IP
+func remoteHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolverResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string // TRUSTED_PROXY_HEADER
+		remoteAddr string
+		xff        string
+		forwarded  string
+		want       string
+	}{
+		{
+			name:       "untrusted peer ignores headers",
+			header:     "x-forwarded-for",
+			remoteAddr: "203.0.113.7:4000",
+			xff:        "6.6.6.6",
+			forwarded:  "for=6.6.6.6",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "x-forwarded-for appended by the proxy",
+			header:     "x-forwarded-for",
+			remoteAddr: "10.0.0.1:4000",
+			xff:        "198.51.100.9",
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "spoofed Forwarded is ignored when proxies write X-Forwarded-For",
+			header:     "x-forwarded-for",
+			remoteAddr: "10.0.0.1:4000",
+			xff:        "198.51.100.9",
+			forwarded:  "for=6.6.6.6",
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "spoofed X-Forwarded-For entry left of the real client",
+			header:     "x-forwarded-for",
+			remoteAddr: "10.0.0.1:4000",
+			xff:        "6.6.6.6, 198.51.100.9",
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "spoofed X-Forwarded-For is ignored when proxies write Forwarded",
+			header:     "forwarded",
+			remoteAddr: "10.0.0.1:4000",
+			xff:        "6.6.6.6",
+			forwarded:  `for="[2001:db8::1]:443"`,
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "forwarded chain through two trusted proxies",
+			header:     "Forwarded",
+			remoteAddr: "10.0.0.1:4000",
+			forwarded:  "for=6.6.6.6, for=198.51.100.9;proto=https, for=10.0.0.2",
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "trusted peer without the header",
+			header:     "forwarded",
+			remoteAddr: "10.0.0.1:4000",
+			xff:        "6.6.6.6",
+			want:       "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewClientIPResolver([]string{"10.0.0.0/8"}, tt.header)
+			if err != nil {
+				t.Fatalf("NewClientIPResolver: %v", err)
+			}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.forwarded != "" {
+				r.Header.Set("Forwarded", tt.forwarded)
+			}
+			if got := resolver.Resolve(r); got != tt.want {
+				t.Errorf("Resolve = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClientIPResolverRejectsUnknownHeader(t *testing.T) {
+	if _, err := NewClientIPResolver(nil, "x-real-ip"); err == nil {
+		t.Fatal("expected an error for an unsupported header")
+	}
+}
@@ -0,0 +1,249 @@
+package middleware
+
+// Request Flow Link:
+// main.go puts Compressor.Middleware directly around the router, inside the access log, so
+// handlers write plain JSON and the access log counts the bytes actually sent.
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"golang_daerah/config"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Content encodings the compressor can produce, in order of preference
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// Compressor compresses response bodies with gzip or deflate, whichever the client prefers
+type Compressor struct {
+	level    int
+	minSize  int
+	gzipPool sync.Pool
+	zlibPool sync.Pool
+}
+
+// NewCompressor validates settings and creates the compressor. Level 0 disables compression,
+// the middleware then only passes requests on.
+func NewCompressor(settings config.CompressionSettings) (*Compressor, error) {
+	if settings.Level < 0 || settings.Level > 9 {
+		return nil, fmt.Errorf("compression level %d, expected 0 (off) to 9", settings.Level)
+	}
+	c := &Compressor{level: settings.Level, minSize: max(settings.MinSize, 0)}
+	c.gzipPool.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, c.level)
+		return w
+	}
+	c.zlibPool.New = func() interface{} {
+		w, _ := zlib.NewWriterLevel(io.Discard, c.level)
+		return w
+	}
+	return c, nil
+}
+
+// Middleware compresses responses of at least the minimum size when the client accepts it.
+// Already compressed content types, responses that set their own Content-Encoding and bodies
+// without content are sent unchanged. Flushes are passed on, so streaming keeps working.
+func (c *Compressor) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.level == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The body depends on Accept-Encoding whether or not this response ends up compressed
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, compressor: c, encoding: encoding, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		// Not deferred: after a panic the buffered body must not go out before the error response
+		cw.finish()
+	}
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header, honoring q-values.
+// It returns "" when the client accepts neither.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		qualities[name] = q
+	}
+
+	for _, encoding := range []string{EncodingGzip, EncodingDeflate} {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// incompressibleType reports content types that are already compressed
+func incompressibleType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"):
+		return true
+	}
+	switch mediaType {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+		"application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+		"application/octet-stream", "font/woff", "font/woff2":
+		return true
+	}
+	return false
+}
+
+// compressWriter buffers the start of the body until it knows whether compressing pays off
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *Compressor
+	encoding   string
+	status     int
+
+	buf     []byte
+	decided bool
+	encoder io.WriteCloser // nil while undecided or when sending unchanged
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if statusCode < http.StatusOK {
+		// Informational responses go out right away and do not end the header
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		w.start(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.compressor.minSize {
+			return len(b), nil
+		}
+		w.start(true)
+		buffered := w.buf
+		w.buf = nil
+		if _, err := w.write(buffered); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	return w.write(b)
+}
+
+// Flush sends what was written so far. An undecided response is compressed right away when
+// its type allows it, since a handler that flushes is usually streaming a long body.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.start(true)
+		buffered := w.buf
+		w.buf = nil
+		w.write(buffered)
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// start writes the header, compressed when wanted and allowed
+func (w *compressWriter) start(compress bool) {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// Sniff now; net/http would otherwise sniff the compressed bytes
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if compress && header.Get("Content-Encoding") == "" && !incompressibleType(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.encoder = w.compressor.encoder(w.encoding, w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *compressWriter) write(b []byte) (int, error) {
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// finish sends a body that stayed below the minimum size, or ends the compressed stream
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.start(false)
+		buffered := w.buf
+		w.buf = nil
+		if len(buffered) > 0 {
+			w.ResponseWriter.Write(buffered)
+		}
+		return
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+		w.compressor.release(w.encoding, w.encoder)
+		w.encoder = nil
+	}
+}
+
+// encoder takes a pooled gzip or zlib writer and points it at dst. HTTP "deflate" is the zlib
+// format, not raw deflate.
+func (c *Compressor) encoder(encoding string, dst io.Writer) io.WriteCloser {
+	if encoding == EncodingGzip {
+		gw := c.gzipPool.Get().(*gzip.Writer)
+		gw.Reset(dst)
+		return gw
+	}
+	zw := c.zlibPool.Get().(*zlib.Writer)
+	zw.Reset(dst)
+	return zw
+}
+
+func (c *Compressor) release(encoding string, encoder io.WriteCloser) {
+	if encoding == EncodingGzip {
+		c.gzipPool.Put(encoder)
+		return
+	}
+	c.zlibPool.Put(encoder)
+}
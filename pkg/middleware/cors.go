@@ -0,0 +1,212 @@
+package middleware
+
+// Request Flow Link:
+// Each route group in main.go starts its chain with the CORS policy of the group, so browser
+// preflights are answered here before authentication and rate limiting, and the headers of
+// actual cross-origin requests are set even when a later middleware rejects them.
+
+import (
+	"fmt"
+	"golang_daerah/config"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSPolicy answers preflights and sets the CORS headers of cross-origin requests
+type CORSPolicy struct {
+	anyOrigin      bool
+	origins        map[string]bool
+	wildcards      []originWildcard
+	methods        map[string]bool
+	allowedMethods string
+	headers        map[string]bool
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+// originWildcard matches "https://*.example.com": any origin with the same scheme whose host
+// ends in the domain, but not the domain itself
+type originWildcard struct {
+	prefix string // "https://"
+	suffix string // ".example.com"
+}
+
+func (o originWildcard) match(origin string) bool {
+	if len(origin) <= len(o.prefix)+len(o.suffix) ||
+		!strings.HasPrefix(origin, o.prefix) || !strings.HasSuffix(origin, o.suffix) {
+		return false
+	}
+	sub := origin[len(o.prefix) : len(origin)-len(o.suffix)]
+	return !strings.ContainsAny(sub, "/:")
+}
+
+// NewCORSPolicy validates settings and creates the policy. A policy without allowed origins
+// adds no headers, which keeps cross-origin requests blocked by the browser.
+func NewCORSPolicy(settings config.CORSPolicy) (*CORSPolicy, error) {
+	p := &CORSPolicy{
+		origins:     make(map[string]bool),
+		methods:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		credentials: settings.AllowCredentials,
+	}
+
+	for _, origin := range settings.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Count(origin, "*") == 1 && strings.Contains(origin, "://*."):
+			star := strings.Index(origin, "*")
+			p.wildcards = append(p.wildcards, originWildcard{prefix: origin[:star], suffix: origin[star+1:]})
+		case strings.Contains(origin, "*"):
+			return nil, fmt.Errorf("cors policy %q: origin %q, only a leading subdomain wildcard like https://*.example.com is supported", settings.Name, origin)
+		default:
+			p.origins[origin] = true
+		}
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, fmt.Errorf("cors policy %q: origin \"*\" cannot be combined with credentials", settings.Name)
+	}
+
+	var methods []string
+	for _, method := range settings.AllowedMethods {
+		method = strings.ToUpper(method)
+		if !p.methods[method] {
+			p.methods[method] = true
+			methods = append(methods, method)
+		}
+	}
+	p.allowedMethods = strings.Join(methods, ", ")
+
+	for _, header := range settings.AllowedHeaders {
+		p.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	p.exposedHeaders = strings.Join(settings.ExposedHeaders, ", ")
+	if settings.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(settings.MaxAge.Seconds()))
+	}
+	return p, nil
+}
+
+// Middleware answers preflights with 204 and passes every other request on. Requests from
+// origins outside the policy get no CORS headers, so the browser refuses them.
+func (p *CORSPolicy) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		enabled := p.anyOrigin || len(p.origins) > 0 || len(p.wildcards) > 0
+
+		if !enabled || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The response depends on Origin even when it is refused, so caches must not share it
+		w.Header().Add("Vary", "Origin")
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			p.preflight(w, r, origin)
+			return
+		}
+
+		if p.allowOrigin(origin) {
+			p.writeOrigin(w, origin)
+			if p.exposedHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", p.exposedHeaders)
+			}
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// preflight answers an OPTIONS preflight. Refused preflights get 204 without CORS headers.
+func (p *CORSPolicy) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requested := splitHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+
+	allowed := p.allowOrigin(origin) && p.methods[method]
+	for _, header := range requested {
+		if !p.headers[http.CanonicalHeaderKey(header)] {
+			allowed = false
+		}
+	}
+
+	if allowed {
+		p.writeOrigin(w, origin)
+		w.Header().Set("Access-Control-Allow-Methods", p.allowedMethods)
+		if len(requested) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+		}
+		if p.maxAge != "" {
+			w.Header().Set("Access-Control-Max-Age", p.maxAge)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *CORSPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, wildcard := range p.wildcards {
+		if wildcard.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *CORSPolicy) writeOrigin(w http.ResponseWriter, origin string) {
+	if p.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// splitHeaderList splits a comma separated header value, dropping empty entries
+func splitHeaderList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// CORSPolicies holds the configured policies by route group name
+type CORSPolicies map[string]*CORSPolicy
+
+// NewCORSPolicies creates one policy per configured route group
+func NewCORSPolicies(settings []config.CORSPolicy) (CORSPolicies, error) {
+	policies := make(CORSPolicies, len(settings))
+	for _, s := range settings {
+		policy, err := NewCORSPolicy(s)
+		if err != nil {
+			return nil, err
+		}
+		policies[s.Name] = policy
+	}
+	return policies, nil
+}
+
+// Middleware returns the middleware of the named policy. It panics for an unknown name, which
+// is a wiring mistake in main.go.
+func (p CORSPolicies) Middleware(name string) func(http.HandlerFunc) http.HandlerFunc {
+	policy, ok := p[name]
+	if !ok {
+		panic(fmt.Sprintf("cors policy %q is not configured", name))
+	}
+	return policy.Middleware
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"log"
+	"time"
+)
+
+// GCRALimiter implements the generic cell rate algorithm. It behaves like a token bucket of
+// size burst refilled at rate per minute, but the store keeps a single theoretical arrival
+// time (TAT) per key, which makes it the cheapest algorithm for a shared store.
+type GCRALimiter struct {
+	store    LimiterStore
+	interval time.Duration // emission interval: time to earn back one request
+	burst    int
+	clock    Clock
+}
+
+// NewGCRALimiter creates a GCRA limiter keeping its TATs in store
+func NewGCRALimiter(rate, burst int, clock Clock, store LimiterStore) *GCRALimiter {
+	return &GCRALimiter{
+		store:    store,
+		interval: rateWindow / time.Duration(rate),
+		burst:    burst,
+		clock:    clock,
+	}
+}
+
+// Allow admits a request for key when its TAT is at most burst intervals ahead of now
+func (l *GCRALimiter) Allow(key string) Decision {
+	d, err := l.store.GCRA(key, l.interval, l.burst, l.clock())
+	if err != nil {
+		log.Printf("rate limit: %v, request allowed", err)
+		return Decision{Allowed: true, Limit: l.burst, Remaining: l.burst}
+	}
+	return d
+}
+
+// gcraDecide computes the decision for a request arriving at now with the stored tat (never
+// before now) and returns the TAT to store. Every store applies this same rule.
+func gcraDecide(tat, now time.Time, interval time.Duration, burst int) (Decision, time.Time) {
+	tolerance := interval * time.Duration(burst)
+	newTAT := tat.Add(interval)
+	d := Decision{Limit: burst}
+
+	if newTAT.Sub(now) > tolerance {
+		// Rejected: the stored TAT stays, the request is not counted
+		d.RetryAfter = newTAT.Sub(now) - tolerance
+		d.ResetAfter = tat.Sub(now)
+		return d, tat
+	}
+
+	d.Allowed = true
+	ahead := newTAT.Sub(now)
+	d.Remaining = int((tolerance - ahead) / interval)
+	if d.Remaining == 0 {
+		d.RetryAfter = ahead + interval - tolerance
+	}
+	d.ResetAfter = ahead
+	return d, newTAT
+}
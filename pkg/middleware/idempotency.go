@@ -0,0 +1,163 @@
+package middleware
+
+// Request Flow Link:
+// main.go wraps every create route with IdempotencyMiddleware (inside AuthMiddleware), so a retried
+// POST carrying the same Idempotency-Key is answered from the stored response instead of reaching
+// the handler a second time.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang_daerah/pkg/jwtutil"
+	"golang_daerah/pkg/logging"
+	"golang_daerah/pkg/response"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header clients use to make a create safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyRecord is the stored state of one Idempotency-Key
+type IdempotencyRecord struct {
+	RequestHash string
+	StatusCode  int // 0 while the original request is still being processed
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+// IdempotencyStore persists keys per user
+type IdempotencyStore interface {
+	// Reserve claims key for owner. If the key already exists the stored record is returned.
+	Reserve(owner, key, requestHash string, ttl time.Duration) (record *IdempotencyRecord, reserved bool, err error)
+	// Complete saves the response produced for a reserved key
+	Complete(owner, key string, statusCode int, body []byte) error
+	// Release forgets a reserved key so that it can be retried
+	Release(owner, key string) error
+	// PurgeExpired deletes keys older than ttl
+	PurgeExpired(ttl time.Duration) error
+}
+
+// IdempotencyMiddleware replays the stored response for a repeated Idempotency-Key within ttl.
+// Requests without the header are passed through unchanged.
+func IdempotencyMiddleware(store IdempotencyStore, ttl time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	go startIdempotencyCleanup(store, ttl)
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				response.WriteBadRequest(w, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			principal, ok := jwtutil.PrincipalFromRequest(r)
+			if !ok {
+				response.WriteUnauthorized(w, "Invalid or expired token")
+				return
+			}
+			owner := principal.Username
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				response.WriteBadRequest(w, "Invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			requestHash := hashRequest(r, body)
+
+			record, reserved, err := store.Reserve(owner, key, requestHash, ttl)
+			if err != nil {
+				response.WriteInternalServerError(w, "Failed to check Idempotency-Key: "+err.Error())
+				return
+			}
+
+			if !reserved {
+				switch {
+				case record.RequestHash != requestHash:
+					response.WriteErrorResponse(w, http.StatusConflict, "Idempotency-Key was already used with a different request")
+				case record.StatusCode == 0:
+					response.WriteErrorResponse(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+				default:
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Idempotent-Replayed", "true")
+					w.WriteHeader(record.StatusCode)
+					w.Write(record.Body)
+				}
+				return
+			}
+
+			rec := &recordingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				// The handler panicked: free the key so the retry is not stuck on "still being
+				// processed". The panic keeps unwinding to RecoverMiddleware with its original stack.
+				if err := store.Release(owner, key); err != nil {
+					logging.FromContext(r.Context()).Error("failed to release idempotency key", "error", err)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+			completed = true
+
+			// Server errors are not stored so the client can retry with the same key
+			if rec.status >= http.StatusInternalServerError {
+				if err := store.Release(owner, key); err != nil {
+					logging.FromContext(r.Context()).Error("failed to release idempotency key", "error", err)
+				}
+				return
+			}
+			if err := store.Complete(owner, key, rec.status, rec.body.Bytes()); err != nil {
+				logging.FromContext(r.Context()).Error("failed to store idempotent response", "error", err)
+			}
+		}
+	}
+}
+
+// hashRequest fingerprints the parts of a request that must match on replay
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// startIdempotencyCleanup removes expired keys so the table does not grow without bound
+func startIdempotencyCleanup(store IdempotencyStore, ttl time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := store.PurgeExpired(ttl); err != nil {
+			slog.Error("idempotency key cleanup failed", "error", err)
+		}
+	}
+}
+
+// recordingResponseWriter passes the response through while keeping a copy of it
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+)
+
+// Decision is the outcome of one Limiter.Allow call
+type Decision struct {
+	Allowed    bool
+	Limit      int           // requests allowed at once (burst, or requests per window)
+	Remaining  int           // requests left after this one
+	RetryAfter time.Duration // wait before the next request is allowed; 0 when Remaining > 0
+	ResetAfter time.Duration // wait until the limit is fully restored
+}
+
+// Limiter decides whether a request counted against key may proceed
+type Limiter interface {
+	Allow(key string) Decision
+}
+
+// Clock returns the current time. Limiters take one so tests can move time by hand.
+type Clock func() time.Time
+
+// Rate limit algorithms selectable per policy
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmGCRA          = "gcra"
+)
+
+// rateWindow is the period rates are expressed in
+const rateWindow = time.Minute
+
+// sweepInterval is how often MemoryStore drops idle keys
+const sweepInterval = 5 * time.Minute
+
+// NewLimiter creates a limiter allowing rate requests per minute. burst is the bucket size of
+// the token bucket and GCRA; the sliding window allows rate requests in any minute and ignores it.
+// State is kept in store. A nil clock uses time.Now.
+func NewLimiter(algorithm string, rate, burst int, clock Clock, store LimiterStore) (Limiter, error) {
+	if rate <= 0 || burst <= 0 {
+		return nil, fmt.Errorf("rate and burst must be positive")
+	}
+	if clock == nil {
+		clock = time.Now
+	}
+
+	switch algorithm {
+	case "", AlgorithmTokenBucket:
+		return NewTokenBucketLimiter(rate, burst, clock, store), nil
+	case AlgorithmSlidingWindow:
+		return NewSlidingWindowLimiter(rate, clock, store), nil
+	case AlgorithmGCRA:
+		return NewGCRALimiter(rate, burst, clock, store), nil
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q, expected %s, %s or %s",
+			algorithm, AlgorithmTokenBucket, AlgorithmSlidingWindow, AlgorithmGCRA)
+	}
+}
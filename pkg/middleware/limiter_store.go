@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"fmt"
+	"golang_daerah/config"
+	"golang_daerah/pkg/resp"
+	"log"
+	"sync"
+	"time"
+)
+
+// LimiterStore holds the per key state of the limiters. Each method applies one request to
+// the state of key atomically and returns the decision; keys of different policies must not
+// collide, which RateLimitPolicy ensures by prefixing them with the policy name.
+type LimiterStore interface {
+	TokenBucket(key string, perSecond float64, burst int, now time.Time) (Decision, error)
+	SlidingWindow(key string, limit int, window time.Duration, now time.Time) (Decision, error)
+	GCRA(key string, interval time.Duration, burst int, now time.Time) (Decision, error)
+}
+
+// NewLimiterStore creates the store selected by settings.Store. The redis store falls back to
+// local limiting while the server cannot be reached.
+func NewLimiterStore(settings config.RateLimitStoreSettings) (LimiterStore, error) {
+	switch settings.Store {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "redis":
+		client := resp.NewClient(settings.RedisAddr, settings.RedisPassword, settings.RedisDB, settings.RedisTimeout)
+		return NewFallbackStore(NewRedisStore(client), NewMemoryStore(), settings.RetryInterval), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q, expected memory or redis", settings.Store)
+	}
+}
+
+// MemoryStore keeps limiter state in a map of this process
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+// memoryEntry is the state of one key; only the fields of its algorithm are used
+type memoryEntry struct {
+	tokens     float64     // token bucket
+	lastRefill time.Time   // token bucket
+	log        []time.Time // sliding window, oldest first
+	tat        time.Time   // GCRA
+	idleAt     time.Time   // from here on the entry equals a fresh one and can be dropped
+}
+
+// NewMemoryStore creates an empty in-process store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+// TokenBucket takes a token from the bucket of key
+func (s *MemoryStore) TokenBucket(key string, perSecond float64, burst int, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(key, now)
+	if entry.lastRefill.IsZero() {
+		entry.tokens = float64(burst)
+		entry.lastRefill = now
+	}
+	tokens := refillTokens(entry.tokens, entry.lastRefill, now, perSecond, burst)
+	d, left := tokenBucketDecision(tokens, perSecond, burst)
+	entry.tokens = left
+	if now.After(entry.lastRefill) {
+		entry.lastRefill = now
+	}
+	entry.idleAt = now.Add(d.ResetAfter)
+	return d, nil
+}
+
+// SlidingWindow logs a request for key if fewer than limit happened in the last window
+func (s *MemoryStore) SlidingWindow(key string, limit int, window time.Duration, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(key, now)
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(entry.log) && !entry.log[i].After(cutoff) {
+		i++
+	}
+	entry.log = entry.log[i:]
+
+	var oldest, newest time.Time
+	if len(entry.log) > 0 {
+		oldest, newest = entry.log[0], entry.log[len(entry.log)-1]
+	}
+	d := slidingWindowDecision(len(entry.log), oldest, newest, now, limit, window)
+	if d.Allowed {
+		entry.log = append(entry.log, now)
+	}
+	entry.idleAt = now.Add(d.ResetAfter)
+	return d, nil
+}
+
+// GCRA advances the theoretical arrival time of key
+func (s *MemoryStore) GCRA(key string, interval time.Duration, burst int, now time.Time) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(key, now)
+	tat := entry.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	d, newTAT := gcraDecide(tat, now, interval, burst)
+	entry.tat = newTAT
+	entry.idleAt = newTAT
+	return d, nil
+}
+
+// entry returns the state of key, creating it if needed. Idle entries are swept at most once
+// per sweepInterval, so the store needs no background goroutine.
+func (s *MemoryStore) entry(key string, now time.Time) *memoryEntry {
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.lastSweep = now
+		for k, e := range s.entries {
+			if !e.idleAt.After(now) {
+				delete(s.entries, k)
+			}
+		}
+	}
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+	return entry
+}
+
+// FallbackStore uses a shared primary store and switches to local limiting while the primary
+// fails. The primary is tried again after retryInterval. Limits are per replica while falling
+// back, which is better than rejecting or allowing everything.
+type FallbackStore struct {
+	primary       LimiterStore
+	local         LimiterStore
+	retryInterval time.Duration
+
+	mu        sync.Mutex
+	downUntil time.Time
+	down      bool
+}
+
+// NewFallbackStore creates a store that prefers primary and falls back to local
+func NewFallbackStore(primary, local LimiterStore, retryInterval time.Duration) *FallbackStore {
+	return &FallbackStore{primary: primary, local: local, retryInterval: retryInterval}
+}
+
+// TokenBucket applies the request to the primary store, or the local one while it is down
+func (s *FallbackStore) TokenBucket(key string, perSecond float64, burst int, now time.Time) (Decision, error) {
+	return s.do(func(store LimiterStore) (Decision, error) {
+		return store.TokenBucket(key, perSecond, burst, now)
+	})
+}
+
+// SlidingWindow applies the request to the primary store, or the local one while it is down
+func (s *FallbackStore) SlidingWindow(key string, limit int, window time.Duration, now time.Time) (Decision, error) {
+	return s.do(func(store LimiterStore) (Decision, error) {
+		return store.SlidingWindow(key, limit, window, now)
+	})
+}
+
+// GCRA applies the request to the primary store, or the local one while it is down
+func (s *FallbackStore) GCRA(key string, interval time.Duration, burst int, now time.Time) (Decision, error) {
+	return s.do(func(store LimiterStore) (Decision, error) {
+		return store.GCRA(key, interval, burst, now)
+	})
+}
+
+func (s *FallbackStore) do(apply func(LimiterStore) (Decision, error)) (Decision, error) {
+	s.mu.Lock()
+	usePrimary := time.Now().After(s.downUntil)
+	s.mu.Unlock()
+	if !usePrimary {
+		return apply(s.local)
+	}
+
+	d, err := apply(s.primary)
+
+	s.mu.Lock()
+	if err != nil {
+		if !s.down {
+			log.Printf("rate limit: shared store unreachable, limiting locally: %v", err)
+		}
+		s.down = true
+		s.downUntil = time.Now().Add(s.retryInterval)
+	} else if s.down {
+		log.Printf("rate limit: shared store reachable again")
+		s.down = false
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return apply(s.local)
+	}
+	return d, nil
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when the test advances it
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// limiterStep advances the clock by after and then expects the decision of one Allow call
+type limiterStep struct {
+	name       string
+	after      time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+// durationTolerance absorbs float rounding in the token bucket refill
+const durationTolerance = time.Millisecond
+
+func runLimiterSteps(t *testing.T, algorithm string, rate, burst int, steps []limiterStep) {
+	t.Helper()
+	clock := newFakeClock()
+	limiter, err := NewLimiter(algorithm, rate, burst, clock.Now, NewMemoryStore())
+	if err != nil {
+		t.Fatalf("NewLimiter: %v", err)
+	}
+
+	for i, step := range steps {
+		clock.Advance(step.after)
+		d := limiter.Allow("client")
+		if d.Allowed != step.allowed {
+			t.Fatalf("step %d (%s): allowed = %v, want %v", i, step.name, d.Allowed, step.allowed)
+		}
+		if d.Remaining != step.remaining {
+			t.Errorf("step %d (%s): remaining = %d, want %d", i, step.name, d.Remaining, step.remaining)
+		}
+		if diff := d.RetryAfter - step.retryAfter; diff < -durationTolerance || diff > durationTolerance {
+			t.Errorf("step %d (%s): retry after = %v, want %v", i, step.name, d.RetryAfter, step.retryAfter)
+		}
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate, burst int
+		steps       []limiterStep
+	}{
+		{
+			name: "fractional refill after 59s",
+			rate: 1, burst: 1,
+			steps: []limiterStep{
+				{name: "first request", allowed: true, remaining: 0, retryAfter: time.Minute},
+				{name: "59/60 of a token", after: 59 * time.Second, allowed: false, remaining: 0, retryAfter: time.Second},
+				{name: "token complete", after: time.Second, allowed: true, remaining: 0, retryAfter: time.Minute},
+			},
+		},
+		{
+			name: "burst exhaustion",
+			rate: 60, burst: 3,
+			steps: []limiterStep{
+				{name: "1", allowed: true, remaining: 2},
+				{name: "2", allowed: true, remaining: 1},
+				{name: "3", allowed: true, remaining: 0, retryAfter: time.Second},
+				{name: "over burst", allowed: false, remaining: 0, retryAfter: time.Second},
+				{name: "half a token", after: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond},
+				{name: "refilled", after: 500 * time.Millisecond, allowed: true, remaining: 0, retryAfter: time.Second},
+			},
+		},
+		{
+			name: "refill stops at burst",
+			rate: 60, burst: 2,
+			steps: []limiterStep{
+				{name: "1", allowed: true, remaining: 1},
+				{name: "idle", after: time.Hour, allowed: true, remaining: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runLimiterSteps(t, AlgorithmTokenBucket, tt.rate, tt.burst, tt.steps)
+		})
+	}
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  int
+		steps []limiterStep
+	}{
+		{
+			name: "boundary eviction",
+			rate: 2,
+			steps: []limiterStep{
+				{name: "t=0", allowed: true, remaining: 1},
+				{name: "t=30s", after: 30 * time.Second, allowed: true, remaining: 0, retryAfter: 30 * time.Second},
+				{name: "t=59.999s, t=0 still inside", after: 29*time.Second + 999*time.Millisecond, allowed: false, remaining: 0, retryAfter: time.Millisecond},
+				{name: "t=60s, t=0 evicted", after: time.Millisecond, allowed: true, remaining: 0, retryAfter: 30 * time.Second},
+				{name: "t=90s, t=30s evicted", after: 30 * time.Second, allowed: true, remaining: 0, retryAfter: 30 * time.Second},
+			},
+		},
+		{
+			name: "rejected requests are not counted",
+			rate: 1,
+			steps: []limiterStep{
+				{name: "t=0", allowed: true, remaining: 0, retryAfter: time.Minute},
+				{name: "t=50s", after: 50 * time.Second, allowed: false, remaining: 0, retryAfter: 10 * time.Second},
+				{name: "t=60s", after: 10 * time.Second, allowed: true, remaining: 0, retryAfter: time.Minute},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runLimiterSteps(t, AlgorithmSlidingWindow, tt.rate, 1, tt.steps)
+		})
+	}
+}
+
+func TestGCRALimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate, burst int
+		steps       []limiterStep
+	}{
+		{
+			name: "retry after values",
+			rate: 60, burst: 2,
+			steps: []limiterStep{
+				{name: "1", allowed: true, remaining: 1},
+				{name: "2", allowed: true, remaining: 0, retryAfter: time.Second},
+				{name: "over burst", allowed: false, remaining: 0, retryAfter: time.Second},
+				{name: "quarter interval", after: 250 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 750 * time.Millisecond},
+				{name: "one interval", after: 750 * time.Millisecond, allowed: true, remaining: 0, retryAfter: time.Second},
+				{name: "idle", after: time.Minute, allowed: true, remaining: 1},
+			},
+		},
+		{
+			name: "slow rate",
+			rate: 2, burst: 1,
+			steps: []limiterStep{
+				{name: "1", allowed: true, remaining: 0, retryAfter: 30 * time.Second},
+				{name: "after 10s", after: 10 * time.Second, allowed: false, remaining: 0, retryAfter: 20 * time.Second},
+				{name: "after 30s", after: 20 * time.Second, allowed: true, remaining: 0, retryAfter: 30 * time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runLimiterSteps(t, AlgorithmGCRA, tt.rate, tt.burst, tt.steps)
+		})
+	}
+}
@@ -1,149 +1,108 @@
 package middleware
 
 // Request Flow Link:
-// main.go wraps every HTTP handler with RateLimitMiddleware, so each incoming request first
-// travels through the logic in this file before any handler/service code runs.
+// main.go builds the named RateLimitPolicies from configuration and wraps every route with one of
+// them, so each incoming request first travels through the logic in this file before any
+// handler/service code runs. Routes wrapped with the same policy share its limiter.
 
 import (
 	"fmt"
+	"golang_daerah/config"
+	"golang_daerah/pkg/jwtutil"
+	"golang_daerah/pkg/response"
+	"math"
 	"net/http"
-	"sync"
-	"time"
+	"strconv"
 )
 
-// RateLimiter implements a simple token bucket rate limiter
-type RateLimiter struct {
-	requests        map[string]*TokenBucket
-	mutex           sync.RWMutex
-	rate            int           // requests per minute
-	burst           int           // maximum burst capacity
-	cleanupInterval time.Duration // cleanup interval for old entries
-}
+// KeyFunc returns the identity a request is counted against
+type KeyFunc func(r *http.Request) string
 
-// TokenBucket represents a token bucket for rate limiting
-type TokenBucket struct {
-	tokens     int
-	lastRefill time.Time
-	rate       int
-	burst      int
+// KeyByIP counts requests per client IP
+func KeyByIP(r *http.Request) string {
+	return "ip:" + ClientIPFromRequest(r)
 }
 
-// NewRateLimiter creates a new rate limiter
-func NewRateLimiter(rate, burst int) *RateLimiter {
-	rl := &RateLimiter{
-		requests:        make(map[string]*TokenBucket),
-		rate:            rate,
-		burst:           burst,
-		cleanupInterval: 5 * time.Minute,
+// KeyByPrincipal counts requests per API key or username and falls back to the client IP for
+// anonymous requests. AuthMiddleware has to run before the policy for the principal to be known.
+func KeyByPrincipal(r *http.Request) string {
+	principal, ok := jwtutil.PrincipalFromRequest(r)
+	switch {
+	case !ok:
+		return KeyByIP(r)
+	case principal.APIKeyID != 0:
+		return "apikey:" + strconv.FormatInt(principal.APIKeyID, 10)
+	case principal.Username != "":
+		return "user:" + principal.Username
 	}
-
-	// Start cleanup goroutine
-	go rl.startCleanup()
-
-	return rl
+	return KeyByIP(r)
 }
 
-// Allow checks if a request is allowed for the given key
-func (rl *RateLimiter) Allow(key string) bool {
-	rl.mutex.Lock()
-	defer rl.mutex.Unlock()
+// RateLimitPolicy is a named limiter shared by every route it wraps
+type RateLimitPolicy struct {
+	Name    string
+	limiter Limiter
+	key     KeyFunc
+}
 
-	now := time.Now()
-	bucket, exists := rl.requests[key]
+// NewRateLimitPolicy creates a policy counting requests per key with limiter
+func NewRateLimitPolicy(name string, limiter Limiter, key KeyFunc) *RateLimitPolicy {
+	return &RateLimitPolicy{Name: name, limiter: limiter, key: key}
+}
 
-	if !exists {
-		// Create new bucket
-		bucket = &TokenBucket{
-			tokens:     rl.burst - 1, // Allow first request
-			lastRefill: now,
-			rate:       rl.rate,
-			burst:      rl.burst,
+// Middleware rejects requests over the limit with 429 and Retry-After. Every response carries
+// the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers (IETF draft, seconds).
+func (p *RateLimitPolicy) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		decision := p.limiter.Allow(p.Name + ":" + p.key(r))
+		writeRateLimitHeaders(w, decision)
+		if !decision.Allowed {
+			response.WriteTooManyRequests(w, decision.RetryAfter, "Rate limit exceeded. Please try again later.")
+			return
 		}
-		rl.requests[key] = bucket
-		return true
-	}
 
-	// Refill tokens based on time passed
-	timePassed := now.Sub(bucket.lastRefill)
-	tokensToAdd := int(timePassed.Minutes()) * bucket.rate
-
-	if tokensToAdd > 0 {
-		bucket.tokens = min(bucket.tokens+tokensToAdd, bucket.burst)
-		bucket.lastRefill = now
-	}
-
-	// Check if we have tokens available
-	if bucket.tokens > 0 {
-		bucket.tokens--
-		return true
+		next.ServeHTTP(w, r)
 	}
-
-	return false
 }
 
-// startCleanup removes old entries to prevent memory leaks
-func (rl *RateLimiter) startCleanup() {
-	ticker := time.NewTicker(rl.cleanupInterval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		rl.mutex.Lock()
-		now := time.Now()
-		for key, bucket := range rl.requests {
-			// Remove entries older than 1 hour
-			if now.Sub(bucket.lastRefill) > time.Hour {
-				delete(rl.requests, key)
-			}
-		}
-		rl.mutex.Unlock()
-	}
+func writeRateLimitHeaders(w http.ResponseWriter, d Decision) {
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(d.ResetAfter.Seconds()))))
 }
 
-// RateLimitMiddleware creates a rate limiting middleware
-func RateLimitMiddleware(rate, burst int) func(http.HandlerFunc) http.HandlerFunc {
-	limiter := NewRateLimiter(rate, burst)
-
-	return func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			// Get client IP as the key for rate limiting
-			clientIP := getClientIP(r)
-
-			if !limiter.Allow(clientIP) {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
-				fmt.Fprintf(w, `{
-					"status": false,
-					"data": [],
-					"message": "Rate limit exceeded. Please try again later."
-				}`)
-				return
-			}
-
-			next.ServeHTTP(w, r)
+// RateLimitPolicies holds the configured policies by name
+type RateLimitPolicies map[string]*RateLimitPolicy
+
+// NewRateLimitPolicies creates one limiter per configured policy, all keeping their state in store
+func NewRateLimitPolicies(settings []config.RateLimitPolicy, store LimiterStore) (RateLimitPolicies, error) {
+	policies := make(RateLimitPolicies, len(settings))
+	for _, s := range settings {
+		var key KeyFunc
+		switch s.Key {
+		case "user":
+			key = KeyByPrincipal
+		case "ip":
+			key = KeyByIP
+		default:
+			return nil, fmt.Errorf("rate limit policy %q: unknown key %q, expected user or ip", s.Name, s.Key)
 		}
+		limiter, err := NewLimiter(s.Algorithm, s.Requests, s.Burst, nil, store)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit policy %q: %w", s.Name, err)
+		}
+		policies[s.Name] = NewRateLimitPolicy(s.Name, limiter, key)
 	}
+	return policies, nil
 }
 
-// getClientIP extracts the real client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies/load balancers)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
-	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	return r.RemoteAddr
-}
-
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+// Middleware returns the middleware of the named policy. It panics for an unknown name, which
+// is a wiring mistake in main.go.
+func (p RateLimitPolicies) Middleware(name string) func(http.HandlerFunc) http.HandlerFunc {
+	policy, ok := p[name]
+	if !ok {
+		panic(fmt.Sprintf("rate limit policy %q is not configured", name))
 	}
-	return b
+	return policy.Middleware
 }
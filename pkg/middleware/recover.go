@@ -0,0 +1,74 @@
+package middleware
+
+// Request Flow Link:
+// RecoverMiddleware is the outermost middleware in main.go. A panic anywhere below it, in a
+// handler, service or driver, ends as a logged stack trace and a JSON 500 instead of a dropped
+// connection.
+
+import (
+	"fmt"
+	"golang_daerah/pkg/logging"
+	"golang_daerah/pkg/response"
+	"net/http"
+	"runtime/debug"
+)
+
+// RecoverMiddleware catches panics, logs them with their stack and answers with the usual error
+// envelope. The message carries a reference, the request ID, which finds the log entry.
+func RecoverMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &panicRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				// Deliberate abort, net/http closes the connection without logging
+				panic(p)
+			}
+
+			// RequestLogMiddleware runs inside this one and has set the ID on the response
+			reference := w.Header().Get(RequestIDHeader)
+			if reference == "" {
+				reference = requestID("")
+				w.Header().Set(RequestIDHeader, reference)
+			}
+			logging.FromContext(r.Context()).Error("panic recovered",
+				"request_id", reference,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(p),
+				"stack", string(debug.Stack()),
+			)
+
+			if rec.wroteHeader {
+				// Part of the response is already out; all that is left is to end it
+				return
+			}
+			response.WriteInternalServerError(w, "Internal server error. Reference: "+reference)
+		}()
+		next.ServeHTTP(rec, r)
+	}
+}
+
+// panicRecorder notes whether the response was started, after which no error can be sent
+type panicRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *panicRecorder) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *panicRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *panicRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
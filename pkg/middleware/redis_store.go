@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"golang_daerah/pkg/resp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Each script applies one request atomically on the server and returns the state the decision
+// is computed from in Go (tokenBucketDecision, slidingWindowDecision, gcraDecide), so a shared
+// store decides exactly like MemoryStore. Times are Unix milliseconds from the replica's
+// clock, so replicas need synchronized clocks.
+
+// tokenBucketScript: KEYS[1] bucket hash; ARGV per_ms, burst, now_ms.
+// Returns the tokens before this request was taken, formatted losslessly.
+const tokenBucketScript = `
+local per_ms = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 't', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * per_ms)
+  ts = now
+end
+local before = tokens
+if tokens >= 1 then
+  tokens = tokens - 1
+end
+redis.call('HSET', KEYS[1], 't', string.format('%.17g', tokens), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / per_ms) + 1000)
+return string.format('%.17g', before)
+`
+
+// slidingWindowScript: KEYS[1] sorted set of request times; ARGV limit, window_ms, now_ms, member.
+// Returns {count, oldest_ms, newest_ms} of the window before this request.
+const slidingWindowScript = `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local oldest = 0
+local newest = 0
+if count > 0 then
+  oldest = tonumber(redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')[2])
+  newest = tonumber(redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')[2])
+end
+if count < limit then
+  redis.call('ZADD', KEYS[1], now, ARGV[4])
+  redis.call('PEXPIRE', KEYS[1], window)
+end
+return {count, oldest, newest}
+`
+
+// gcraScript: KEYS[1] TAT; ARGV interval_ms, burst, now_ms.
+// Returns the TAT the request was judged against (never before now).
+const gcraScript = `
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if tat == nil or tat < now then
+  tat = now
+end
+local new_tat = tat + interval
+if new_tat - now <= interval * burst then
+  redis.call('SET', KEYS[1], new_tat, 'PX', new_tat - now)
+end
+return tat
+`
+
+// redisScript is a script together with its SHA1 for EVALSHA
+type redisScript struct {
+	source string
+	sha    string
+}
+
+func newRedisScript(source string) redisScript {
+	sum := sha1.Sum([]byte(source))
+	return redisScript{source: source, sha: hex.EncodeToString(sum[:])}
+}
+
+var (
+	tokenBucketRedisScript   = newRedisScript(tokenBucketScript)
+	slidingWindowRedisScript = newRedisScript(slidingWindowScript)
+	gcraRedisScript          = newRedisScript(gcraScript)
+)
+
+// RedisStore keeps limiter state in a server speaking the Redis protocol, shared by all replicas
+type RedisStore struct {
+	client *resp.Client
+	prefix string
+}
+
+// NewRedisStore creates a store using client; keys are prefixed with "ratelimit:"
+func NewRedisStore(client *resp.Client) *RedisStore {
+	return &RedisStore{client: client, prefix: "ratelimit:"}
+}
+
+// TokenBucket takes a token from the bucket of key
+func (s *RedisStore) TokenBucket(key string, perSecond float64, burst int, now time.Time) (Decision, error) {
+	perMs := strconv.FormatFloat(perSecond/1000, 'g', -1, 64)
+	reply, err := s.eval(tokenBucketRedisScript, key, perMs, strconv.Itoa(burst), unixMilli(now))
+	if err != nil {
+		return Decision{}, err
+	}
+	bulk, ok := reply.([]byte)
+	if !ok {
+		return Decision{}, fmt.Errorf("rate limit store: unexpected token bucket reply %v", reply)
+	}
+	tokens, err := strconv.ParseFloat(string(bulk), 64)
+	if err != nil {
+		return Decision{}, fmt.Errorf("rate limit store: %w", err)
+	}
+	d, _ := tokenBucketDecision(tokens, perSecond, burst)
+	return d, nil
+}
+
+// SlidingWindow logs a request for key if fewer than limit happened in the last window
+func (s *RedisStore) SlidingWindow(key string, limit int, window time.Duration, now time.Time) (Decision, error) {
+	now = now.Truncate(time.Millisecond)
+	reply, err := s.eval(slidingWindowRedisScript, key,
+		strconv.Itoa(limit), strconv.FormatInt(window.Milliseconds(), 10), unixMilli(now), requestMember(now))
+	if err != nil {
+		return Decision{}, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 3 {
+		return Decision{}, fmt.Errorf("rate limit store: unexpected sliding window reply %v", reply)
+	}
+	count, _ := values[0].(int64)
+	oldest, _ := values[1].(int64)
+	newest, _ := values[2].(int64)
+	return slidingWindowDecision(int(count), time.UnixMilli(oldest), time.UnixMilli(newest), now, limit, window), nil
+}
+
+// GCRA advances the theoretical arrival time of key
+func (s *RedisStore) GCRA(key string, interval time.Duration, burst int, now time.Time) (Decision, error) {
+	now = now.Truncate(time.Millisecond)
+	interval = max(interval.Truncate(time.Millisecond), time.Millisecond)
+	reply, err := s.eval(gcraRedisScript, key,
+		strconv.FormatInt(interval.Milliseconds(), 10), strconv.Itoa(burst), unixMilli(now))
+	if err != nil {
+		return Decision{}, err
+	}
+	tat, ok := reply.(int64)
+	if !ok {
+		return Decision{}, fmt.Errorf("rate limit store: unexpected GCRA reply %v", reply)
+	}
+	d, _ := gcraDecide(time.UnixMilli(tat), now, interval, burst)
+	return d, nil
+}
+
+// eval runs script by SHA and loads it with EVAL when the server does not know it yet
+func (s *RedisStore) eval(script redisScript, key string, args ...string) (interface{}, error) {
+	ctx := context.Background()
+	cmd := append([]string{"EVALSHA", script.sha, "1", s.prefix + key}, args...)
+	reply, err := s.client.Do(ctx, cmd...)
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		cmd[0], cmd[1] = "EVAL", script.source
+		reply, err = s.client.Do(ctx, cmd...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rate limit store: %w", err)
+	}
+	return reply, nil
+}
+
+func unixMilli(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// requestMember makes sorted set members unique when requests share a millisecond
+func requestMember(now time.Time) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return unixMilli(now) + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,305 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"golang_daerah/pkg/resp"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-process server speaking enough RESP2 for RedisStore. Lua is not
+// available, so each known script is emulated in Go by its SHA; EVALSHA answers NOSCRIPT
+// until the script was sent with EVAL, like a freshly started server.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	loaded  map[string]bool
+	evals   int // EVAL calls, i.e. script loads
+	buckets map[string][2]float64
+	windows map[string][]int64
+	tats    map[string]int64
+}
+
+func startFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeRedis{
+		listener: listener,
+		loaded:   make(map[string]bool),
+		buckets:  make(map[string][2]float64),
+		windows:  make(map[string][]int64),
+		tats:     make(map[string]int64),
+	}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeRedis) Addr() string { return s.listener.Addr().String() }
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		request, err := resp.ReadReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := request.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			b, _ := item.([]byte)
+			args[i] = string(b)
+		}
+		writeValue(w, s.command(args))
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeRedis) command(args []string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(args) < 4 {
+		return resp.Error("ERR wrong number of arguments")
+	}
+	var sha string
+	switch strings.ToUpper(args[0]) {
+	case "EVALSHA":
+		sha = args[1]
+		if !s.loaded[sha] {
+			return resp.Error("NOSCRIPT No matching script. Please use EVAL.")
+		}
+	case "EVAL":
+		sha = newRedisScript(args[1]).sha
+		s.loaded[sha] = true
+		s.evals++
+	default:
+		return resp.Error("ERR unknown command '" + args[0] + "'")
+	}
+
+	key, argv := args[3], args[4:]
+	switch sha {
+	case tokenBucketRedisScript.sha:
+		return s.tokenBucket(key, argv)
+	case slidingWindowRedisScript.sha:
+		return s.slidingWindow(key, argv)
+	case gcraRedisScript.sha:
+		return s.gcra(key, argv)
+	}
+	return resp.Error("ERR unknown script")
+}
+
+// tokenBucket mirrors tokenBucketScript
+func (s *fakeRedis) tokenBucket(key string, argv []string) interface{} {
+	perMs, _ := strconv.ParseFloat(argv[0], 64)
+	burst, _ := strconv.ParseFloat(argv[1], 64)
+	now, _ := strconv.ParseFloat(argv[2], 64)
+	state, ok := s.buckets[key]
+	if !ok {
+		state = [2]float64{burst, now}
+	}
+	tokens, ts := state[0], state[1]
+	if now > ts {
+		tokens = math.Min(burst, tokens+(now-ts)*perMs)
+		ts = now
+	}
+	before := tokens
+	if tokens >= 1 {
+		tokens--
+	}
+	s.buckets[key] = [2]float64{tokens, ts}
+	return []byte(strconv.FormatFloat(before, 'g', 17, 64))
+}
+
+// slidingWindow mirrors slidingWindowScript
+func (s *fakeRedis) slidingWindow(key string, argv []string) interface{} {
+	limit, _ := strconv.Atoi(argv[0])
+	window, _ := strconv.ParseInt(argv[1], 10, 64)
+	now, _ := strconv.ParseInt(argv[2], 10, 64)
+	var kept []int64
+	for _, score := range s.windows[key] {
+		if score > now-window {
+			kept = append(kept, score)
+		}
+	}
+	var oldest, newest int64
+	if len(kept) > 0 {
+		oldest, newest = kept[0], kept[len(kept)-1]
+	}
+	count := int64(len(kept))
+	if len(kept) < limit {
+		kept = append(kept, now)
+		sort.Slice(kept, func(i, j int) bool { return kept[i] < kept[j] })
+	}
+	s.windows[key] = kept
+	return []interface{}{count, oldest, newest}
+}
+
+// gcra mirrors gcraScript
+func (s *fakeRedis) gcra(key string, argv []string) interface{} {
+	interval, _ := strconv.ParseInt(argv[0], 10, 64)
+	burst, _ := strconv.ParseInt(argv[1], 10, 64)
+	now, _ := strconv.ParseInt(argv[2], 10, 64)
+	tat, ok := s.tats[key]
+	if !ok || tat < now {
+		tat = now
+	}
+	if newTAT := tat + interval; newTAT-now <= interval*burst {
+		s.tats[key] = newTAT
+	}
+	return tat
+}
+
+func writeValue(w *bufio.Writer, value interface{}) {
+	switch v := value.(type) {
+	case resp.Error:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeValue(w, item)
+		}
+	}
+}
+
+// storeCall applies one request for key to a store
+type storeCall func(store LimiterStore, key string, now time.Time) (Decision, error)
+
+var storeCalls = []struct {
+	name string
+	call storeCall
+}{
+	{"token bucket", func(store LimiterStore, key string, now time.Time) (Decision, error) {
+		return store.TokenBucket(key, 1, 3, now)
+	}},
+	{"sliding window", func(store LimiterStore, key string, now time.Time) (Decision, error) {
+		return store.SlidingWindow(key, 3, 10*time.Second, now)
+	}},
+	{"gcra", func(store LimiterStore, key string, now time.Time) (Decision, error) {
+		return store.GCRA(key, time.Second, 3, now)
+	}},
+}
+
+// compareWithMemoryStore sends the same requests for key to store and to a fresh MemoryStore
+// and fails on the first decision that differs
+func compareWithMemoryStore(t *testing.T, store LimiterStore, key string, call storeCall) {
+	t.Helper()
+	// Bursts, waits shorter than one interval and a long pause, all on whole milliseconds
+	offsets := []time.Duration{0, 0, 0, 0, 250 * time.Millisecond, time.Second, 1500 * time.Millisecond,
+		1500 * time.Millisecond, 4 * time.Second, 12 * time.Second, 12 * time.Second}
+	memory := NewMemoryStore()
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, offset := range offsets {
+		now := start.Add(offset)
+		got, err := call(store, key, now)
+		if err != nil {
+			t.Fatalf("request %d: redis store: %v", i, err)
+		}
+		want, _ := call(memory, key, now)
+		if got.Allowed != want.Allowed || got.Remaining != want.Remaining || got.Limit != want.Limit {
+			t.Fatalf("request %d at +%v: redis %+v, memory %+v", i, offset, got, want)
+		}
+		for _, d := range [][2]time.Duration{{got.RetryAfter, want.RetryAfter}, {got.ResetAfter, want.ResetAfter}} {
+			if diff := d[0] - d[1]; diff < -durationTolerance || diff > durationTolerance {
+				t.Fatalf("request %d at +%v: redis %+v, memory %+v", i, offset, got, want)
+			}
+		}
+	}
+}
+
+// TestRedisStoreProtocol runs RedisStore against fakeRedis. The Lua scripts are not executed
+// (the fake mirrors them in Go), so this covers argument encoding, reply decoding, the decision
+// math in Go and the NOSCRIPT fallback, not the scripts; TestRedisStoreScripts runs those.
+func TestRedisStoreProtocol(t *testing.T) {
+	for _, tt := range storeCalls {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startFakeRedis(t)
+			client := resp.NewClient(server.Addr(), "", 0, time.Second)
+			defer client.Close()
+
+			compareWithMemoryStore(t, NewRedisStore(client), "client", tt.call)
+
+			if server.evals != 1 {
+				t.Errorf("script loaded %d times, want once and EVALSHA afterwards", server.evals)
+			}
+		})
+	}
+}
+
+// TestRedisStoreScripts runs the Lua scripts on a real server and checks that RedisStore decides
+// like MemoryStore. It needs RATE_LIMIT_TEST_REDIS_ADDR (e.g. localhost:6379) and is skipped
+// otherwise. Keys are unique per run, so the database does not have to be empty.
+func TestRedisStoreScripts(t *testing.T) {
+	addr := os.Getenv("RATE_LIMIT_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("RATE_LIMIT_TEST_REDIS_ADDR not set")
+	}
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	for _, tt := range storeCalls {
+		t.Run(tt.name, func(t *testing.T) {
+			client := resp.NewClient(addr, os.Getenv("RATE_LIMIT_TEST_REDIS_PASSWORD"), 0, time.Second)
+			defer client.Close()
+
+			key := "test:" + run + ":" + strings.ReplaceAll(tt.name, " ", "_")
+			compareWithMemoryStore(t, NewRedisStore(client), key, tt.call)
+		})
+	}
+}
+
+func TestFallbackStoreLimitsLocallyWhenDialFails(t *testing.T) {
+	// Reserve a port and close it again so dialing it is refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	client := resp.NewClient(addr, "", 0, 100*time.Millisecond)
+	if _, err := NewRedisStore(client).GCRA("client", time.Second, 1, time.Now()); err == nil {
+		t.Fatal("redis store: expected a dial error")
+	}
+
+	store := NewFallbackStore(NewRedisStore(client), NewMemoryStore(), time.Minute)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, wantAllowed := range []bool{true, true, false} {
+		d, err := store.TokenBucket("client", 1, 2, now)
+		if err != nil {
+			t.Fatalf("request %d: fallback store returned %v", i, err)
+		}
+		if d.Allowed != wantAllowed {
+			t.Fatalf("request %d: allowed = %v, want %v; the memory store should enforce the limit", i, d.Allowed, wantAllowed)
+		}
+	}
+}
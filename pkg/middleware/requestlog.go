@@ -0,0 +1,116 @@
+package middleware
+
+// Request Flow Link:
+// main.go wraps the router with RequestLogMiddleware right inside the client IP resolver. It
+// gives every request an ID, puts a logger carrying that ID into the context and writes one
+// access log line after the handler returned.
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"golang_daerah/pkg/logging"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// RequestIDHeader carries the request ID. An ID sent by the client or a proxy is kept so logs
+// of several services can be joined; otherwise a new one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds incoming IDs so clients cannot bloat every log line
+const maxRequestIDLength = 128
+
+// RequestLogMiddleware assigns the request ID, echoes it in the response and logs method,
+// route, status, bytes, duration, user and client IP of every request
+func RequestLogMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &logging.RequestInfo{ID: requestID(r.Header.Get(RequestIDHeader))}
+		w.Header().Set(RequestIDHeader, info.ID)
+
+		// The router records the matched pattern on the request it is given, so keep that copy
+		req := r.WithContext(logging.WithRequest(r.Context(), info))
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed && !rec.wroteHeader {
+				// A panic is on its way to RecoverMiddleware, which answers with a 500
+				rec.status = http.StatusInternalServerError
+			}
+			logRequest(r, req, rec, info, start)
+		}()
+		next.ServeHTTP(rec, req)
+		completed = true
+	}
+}
+
+// logRequest writes the access log line of a finished request. r is the request as received,
+// req the copy the router saw.
+func logRequest(r, req *http.Request, rec *statusRecorder, info *logging.RequestInfo, start time.Time) {
+	level := slog.LevelInfo
+	if rec.status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logging.FromContext(req.Context()).LogAttrs(req.Context(), level, "request",
+		slog.String("method", r.Method),
+		slog.String("route", req.Pattern),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", rec.status),
+		slog.Int64("bytes", rec.bytes),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.String("user", info.User()),
+		slog.String("client_ip", ClientIPFromRequest(r)),
+	)
+}
+
+// requestID returns incoming when it is a usable ID and a new random one otherwise
+func requestID(incoming string) string {
+	if validRequestID(incoming) {
+		return incoming
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts printable ASCII without spaces, which is safe to log and to echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder remembers the status code and counts the body bytes written
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.status = statusCode
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
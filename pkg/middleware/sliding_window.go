@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"log"
+	"time"
+)
+
+// SlidingWindowLimiter allows at most rate requests in any one minute window. The store keeps
+// the timestamp of every allowed request in the window, so it is exact at the cost of memory.
+type SlidingWindowLimiter struct {
+	store  LimiterStore
+	limit  int
+	window time.Duration
+	clock  Clock
+}
+
+// NewSlidingWindowLimiter creates a sliding window log limiter keeping its logs in store
+func NewSlidingWindowLimiter(rate int, clock Clock, store LimiterStore) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{store: store, limit: rate, window: rateWindow, clock: clock}
+}
+
+// Allow records a request for key if fewer than limit happened in the last window
+func (l *SlidingWindowLimiter) Allow(key string) Decision {
+	d, err := l.store.SlidingWindow(key, l.limit, l.window, l.clock())
+	if err != nil {
+		log.Printf("rate limit: %v, request allowed", err)
+		return Decision{Allowed: true, Limit: l.limit, Remaining: l.limit}
+	}
+	return d
+}
+
+// slidingWindowDecision decides a request arriving at now when count requests, the oldest at
+// oldest and the newest at newest, are still inside the window. Every store applies this rule.
+func slidingWindowDecision(count int, oldest, newest, now time.Time, limit int, window time.Duration) Decision {
+	d := Decision{Limit: limit}
+	if count < limit {
+		d.Allowed = true
+		if count == 0 {
+			oldest = now
+		}
+		newest = now
+		count++
+	}
+
+	d.Remaining = limit - count
+	if d.Remaining == 0 {
+		// The oldest request leaves the window first
+		d.RetryAfter = oldest.Add(window).Sub(now)
+	}
+	d.ResetAfter = newest.Add(window).Sub(now)
+	return d
+}
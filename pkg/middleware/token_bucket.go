@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// TokenBucketLimiter refills every bucket continuously at rate tokens per minute, up to burst.
+// Partial tokens are kept, so a client calling every 59 seconds still earns tokens back.
+type TokenBucketLimiter struct {
+	store     LimiterStore
+	perSecond float64
+	burst     int
+	clock     Clock
+}
+
+// NewTokenBucketLimiter creates a token bucket limiter keeping its buckets in store
+func NewTokenBucketLimiter(rate, burst int, clock Clock, store LimiterStore) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		store:     store,
+		perSecond: float64(rate) / rateWindow.Seconds(),
+		burst:     burst,
+		clock:     clock,
+	}
+}
+
+// Allow takes one token from the bucket of key
+func (l *TokenBucketLimiter) Allow(key string) Decision {
+	d, err := l.store.TokenBucket(key, l.perSecond, l.burst, l.clock())
+	if err != nil {
+		log.Printf("rate limit: %v, request allowed", err)
+		return Decision{Allowed: true, Limit: l.burst, Remaining: l.burst}
+	}
+	return d
+}
+
+// refillTokens returns the tokens of a bucket last refilled at last, now
+func refillTokens(tokens float64, last, now time.Time, perSecond float64, burst int) float64 {
+	elapsed := now.Sub(last).Seconds()
+	if elapsed <= 0 {
+		return tokens
+	}
+	return math.Min(float64(burst), tokens+elapsed*perSecond)
+}
+
+// tokenBucketDecision takes a token from a bucket holding tokens (already refilled) and returns
+// the decision and the tokens left. Every store applies this same rule.
+func tokenBucketDecision(tokens, perSecond float64, burst int) (Decision, float64) {
+	d := Decision{Limit: burst}
+	if tokens >= 1 {
+		tokens--
+		d.Allowed = true
+	}
+	d.Remaining = int(math.Floor(tokens))
+	if tokens < 1 {
+		d.RetryAfter = tokensDuration(1-tokens, perSecond)
+	}
+	d.ResetAfter = tokensDuration(float64(burst)-tokens, perSecond)
+	return d, tokens
+}
+
+// tokensDuration returns how long it takes to earn tokens
+func tokensDuration(tokens, perSecond float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / perSecond * float64(time.Second)))
+}
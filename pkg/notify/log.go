@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogNotifier writes messages to a file, or to the application log when Path is empty.
+// It is meant for local development and testing only: reset messages carry live tokens, so
+// anyone who can read the output can take the accounts over.
+type LogNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Send appends msg to the file or log
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	if n.Path == "" {
+		log.Printf("notify: to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open notify log: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "--- %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().UTC().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
@@ -0,0 +1,38 @@
+package notify
+
+// Request Flow Link:
+// main.go builds a Notifier from configuration with New and hands it to the AuthService,
+// which uses it to deliver password reset links.
+
+import (
+	"context"
+	"fmt"
+	"golang_daerah/config"
+)
+
+// Message is a plain text message to one recipient
+type Message struct {
+	To      string // email address
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New builds the notifier selected by settings.Kind. An empty Kind is an error rather than
+// defaulting to log, which writes live reset tokens wherever the messages end up.
+func New(settings config.NotifierSettings) (Notifier, error) {
+	switch settings.Kind {
+	case "":
+		return nil, fmt.Errorf("NOTIFIER is not set, expected log or smtp")
+	case "log":
+		return &LogNotifier{Path: settings.LogFile}, nil
+	case "smtp":
+		return NewSMTPNotifier(settings.SMTP), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q, expected log or smtp", settings.Kind)
+	}
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"golang_daerah/config"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier sends messages as plain text mail. Any SMTP server works, including local
+// stand-ins such as MailHog or smtp4dev (SMTP_STARTTLS=false, no credentials).
+type SMTPNotifier struct {
+	settings config.SMTPSettings
+	timeout  time.Duration
+}
+
+// NewSMTPNotifier creates an SMTP notifier
+func NewSMTPNotifier(settings config.SMTPSettings) *SMTPNotifier {
+	return &SMTPNotifier{settings: settings, timeout: 30 * time.Second}
+}
+
+// Send delivers msg. The whole conversation is bounded by ctx and a 30 second timeout.
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", msg.To)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(n.settings.Host, n.settings.Port)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("smtp dial %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, n.settings.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if n.settings.StartTLS {
+		// Never fall back to plaintext: credentials and reset links would travel in the clear
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp starttls: %s does not offer STARTTLS, set SMTP_STARTTLS=false to send without TLS", addr)
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: n.settings.Host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if n.settings.Username != "" {
+		auth := smtp.PlainAuth("", n.settings.Username, n.settings.Password, n.settings.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(n.settings.From); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(n.compose(msg)); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return client.Quit()
+}
+
+// compose renders the RFC 5322 message with CRLF line endings
+func (n *SMTPNotifier) compose(msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + n.settings.From + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"golang_daerah/config"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is an in-process server speaking just enough SMTP for one delivery. It records
+// the commands it received and the message sent with DATA.
+type fakeSMTP struct {
+	listener   net.Listener
+	extensions []string // advertised in the EHLO reply
+
+	mu       sync.Mutex
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func startFakeSMTP(t *testing.T, extensions ...string) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTP{listener: listener, extensions: extensions, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeSMTP) settings(startTLS bool) config.SMTPSettings {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return config.SMTPSettings{Host: host, Port: port, From: "no-reply@example.com", StartTLS: startTLS}
+}
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		verb, _, _ := strings.Cut(strings.ToUpper(line), " ")
+		switch verb {
+		case "EHLO":
+			lines := append([]string{"fake"}, s.extensions...)
+			for i, ext := range lines {
+				if i == len(lines)-1 {
+					reply("250 " + ext)
+				} else {
+					reply("250-" + ext)
+				}
+			}
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var b strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				b.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.data = b.String()
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// received waits for the conversation to end and returns what the server saw
+func (s *fakeSMTP) received() ([]string, string) {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands, s.data
+}
+
+func TestSMTPNotifierSend(t *testing.T) {
+	server := startFakeSMTP(t, "8BITMIME")
+	notifier := NewSMTPNotifier(server.settings(false))
+
+	err := notifier.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "Open this link:\nhttps://example.com/reset?token=abc",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	commands, data := server.received()
+	want := []string{"EHLO", "MAIL FROM:<no-reply@example.com>", "RCPT TO:<user@example.com>", "DATA", "QUIT"}
+	if len(commands) != len(want) {
+		t.Fatalf("commands = %q, want %q", commands, want)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(commands[i], prefix) {
+			t.Fatalf("command %d = %q, want prefix %q (all: %q)", i, commands[i], prefix, commands)
+		}
+	}
+
+	header, body, ok := strings.Cut(data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message without header/body separator: %q", data)
+	}
+	for _, h := range []string{
+		"From: no-reply@example.com",
+		"To: user@example.com",
+		"Subject: Reset your password",
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Date: ",
+	} {
+		if !strings.Contains(header, h) {
+			t.Errorf("header lacks %q:\n%s", h, header)
+		}
+	}
+	if want := "Open this link:\r\nhttps://example.com/reset?token=abc\r\n"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestSMTPNotifierRefusesPlaintextWhenStartTLSMissing(t *testing.T) {
+	server := startFakeSMTP(t, "8BITMIME") // STARTTLS not advertised
+	notifier := NewSMTPNotifier(server.settings(true))
+
+	err := notifier.Send(context.Background(), Message{To: "user@example.com", Subject: "s", Body: "b"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("Send error = %v, want a STARTTLS error", err)
+	}
+
+	commands, data := server.received()
+	for _, c := range commands {
+		if strings.HasPrefix(c, "MAIL") || strings.HasPrefix(c, "RCPT") || c == "DATA" {
+			t.Fatalf("sent %q in plaintext after STARTTLS was required (all: %q)", c, commands)
+		}
+	}
+	if data != "" {
+		t.Fatalf("message delivered in plaintext: %q", data)
+	}
+}
@@ -0,0 +1,215 @@
+package resp
+
+// Request Flow Link:
+// The rate limiter's RedisStore sends its scripts through Client.Do. Any server speaking the
+// Redis serialization protocol (RESP2) works: Redis, Valkey, KeyDB or an in-process fake.
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Error is an error reply sent by the server, e.g. "NOSCRIPT No matching script"
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// maxIdleConns is how many connections are kept open between commands
+const maxIdleConns = 8
+
+// Client sends commands over a small pool of connections. Replies are decoded as
+// string (simple string), Error, int64, []byte (bulk string, nil for null) or []interface{}.
+type Client struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration // dial and per command timeout
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// NewClient creates a client; connections are opened on first use
+func NewClient(addr, password string, db int, timeout time.Duration) *Client {
+	return &Client{addr: addr, password: password, db: db, timeout: timeout}
+}
+
+// Do sends one command and reads its reply. Error replies are returned as Error; network and
+// protocol errors close the connection.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.roundTrip(ctx, c.timeout, args)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Close closes the idle connections
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= maxIdleConns {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := net.Dialer{Timeout: c.timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	if c.password != "" {
+		if _, err := cn.roundTrip(ctx, c.timeout, []string{"AUTH", c.password}); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.roundTrip(ctx, c.timeout, []string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("select %d: %w", c.db, err)
+		}
+	}
+	return cn, nil
+}
+
+func (cn *conn) roundTrip(ctx context.Context, timeout time.Duration, args []string) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	cn.SetDeadline(deadline)
+
+	if err := writeCommand(cn.w, args); err != nil {
+		return nil, err
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	return ReadReply(cn.r)
+}
+
+// writeCommand encodes args as an array of bulk strings
+func writeCommand(w *bufio.Writer, args []string) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return nil
+}
+
+// ReadReply decodes one RESP2 value. An error reply is returned as Error together with a nil
+// value; nested error replies inside arrays are returned as Error values.
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("resp: empty reply line")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("resp: bad bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("resp: bad array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := ReadReply(r)
+			var replyErr Error
+			if errors.As(err, &replyErr) {
+				item, err = replyErr, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("resp: unexpected reply %q", line)
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("resp: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
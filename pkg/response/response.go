@@ -8,7 +8,11 @@ import (
 	"encoding/json"
 	// "errors"
 	// "fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 	// "golang_daerah/pkg/jwtutil"
 	// "time"
 	// "golang.org/x/crypto/bcrypt"
@@ -45,6 +49,18 @@ func WriteSuccessResponse(w http.ResponseWriter, statusCode int, data interface{
 	})
 }
 
+// WriteErrorResponseWithData writes an error response that still carries a payload,
+// e.g. per-item results of a batch where nothing was saved
+func WriteErrorResponseWithData(w http.ResponseWriter, statusCode int, data interface{}, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(Response{
+		Status:  false,
+		Data:    data,
+		Message: message,
+	})
+}
+
 // WriteSuccessResponseOK writes a 200 OK success response (most common)
 func WriteSuccessResponseOK(w http.ResponseWriter, data interface{}, message string) {
 	WriteSuccessResponse(w, http.StatusOK, data, message)
@@ -55,6 +71,11 @@ func WriteSuccessResponseCreated(w http.ResponseWriter, data interface{}, messag
 	WriteSuccessResponse(w, http.StatusCreated, data, message)
 }
 
+// WriteMultiStatus writes a 207 Multi-Status response for batches where only some items succeeded
+func WriteMultiStatus(w http.ResponseWriter, data interface{}, message string) {
+	WriteSuccessResponse(w, http.StatusMultiStatus, data, message)
+}
+
 // WritePaginatedResponse writes a success response with pagination info
 func WritePaginatedResponse(w http.ResponseWriter, data interface{}, page, perPage int, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -77,7 +98,54 @@ func WriteUnauthorized(w http.ResponseWriter, message string) {
 }
 
 func WriteMethodNotAllowed(w http.ResponseWriter) {
-	WriteErrorResponse(w, http.StatusMethodNotAllowed, "Only POST method allowed")
+	WriteMethodNotAllowedFor(w, http.MethodPost)
+}
+
+// WriteMethodNotAllowedFor writes a 405 listing the methods the route accepts
+func WriteMethodNotAllowedFor(w http.ResponseWriter, methods ...string) {
+	allowed := strings.Join(methods, ", ")
+	w.Header().Set("Allow", allowed)
+	if len(methods) == 1 {
+		WriteErrorResponse(w, http.StatusMethodNotAllowed, "Only "+allowed+" method allowed")
+		return
+	}
+	WriteErrorResponse(w, http.StatusMethodNotAllowed, "Only "+allowed+" methods allowed")
+}
+
+func WriteForbidden(w http.ResponseWriter, message string) {
+	WriteErrorResponse(w, http.StatusForbidden, message)
+}
+
+func WriteNotFound(w http.ResponseWriter, message string) {
+	WriteErrorResponse(w, http.StatusNotFound, message)
+}
+
+func WritePreconditionFailed(w http.ResponseWriter, message string) {
+	WriteErrorResponse(w, http.StatusPreconditionFailed, message)
+}
+
+func WritePreconditionRequired(w http.ResponseWriter, message string) {
+	WriteErrorResponse(w, http.StatusPreconditionRequired, message)
+}
+
+// WriteTooManyRequests writes a 429 telling the client when to retry
+func WriteTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	WriteErrorResponse(w, http.StatusTooManyRequests, message)
+}
+
+// WritePayloadTooLarge writes a 413 naming the body size limit
+func WritePayloadTooLarge(w http.ResponseWriter, limit int64) {
+	WriteErrorResponse(w, http.StatusRequestEntityTooLarge,
+		"Request body too large, the limit is "+strconv.FormatInt(limit, 10)+" bytes")
+}
+
+func WriteUnsupportedMediaType(w http.ResponseWriter, message string) {
+	WriteErrorResponse(w, http.StatusUnsupportedMediaType, message)
 }
 
 func WriteInternalServerError(w http.ResponseWriter, message string) {